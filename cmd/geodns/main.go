@@ -3,14 +3,29 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/JaveleyQAQ/geodns/internal/backend"
+	"github.com/JaveleyQAQ/geodns/internal/client"
 	"github.com/JaveleyQAQ/geodns/internal/config"
+	"github.com/JaveleyQAQ/geodns/internal/dnssec"
+	"github.com/JaveleyQAQ/geodns/internal/geoip"
 	"github.com/JaveleyQAQ/geodns/internal/input"
+	"github.com/JaveleyQAQ/geodns/internal/query"
 	"github.com/JaveleyQAQ/geodns/internal/service"
 	"github.com/JaveleyQAQ/geodns/pkg/logo"
+	"github.com/miekg/dns"
 )
 
+// ttlRecordDir 录制目录中保存权威TTL查询的子目录
+const ttlRecordDir = "ttl"
+
 func printUsage() {
 	fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
 	fmt.Println("  Input options:")
@@ -31,10 +46,25 @@ func printUsage() {
 	fmt.Println("    -axfr\t查询AXFR记录")
 	fmt.Println("    -caa\t查询CAA记录")
 	fmt.Println("    -recon\t查询所有类型")
+	fmt.Println("    -class string\t查询类 (IN/CH/HS或编号，doh-json模式只支持IN) (default IN)")
+	fmt.Println("    -identify\t在每个区域查询CH类的version.bind、hostname.bind、id.server，识别解析器软件和实例")
+	fmt.Println("    -type string\t查询任意记录类型，逗号分隔的类型名或编号 (如 HTTPS,SVCB,TLSA,DS,65)")
+	fmt.Println("    -edns\t添加EDNS0 OPT记录并显示各区域返回的EDNS0信息")
+	fmt.Println("    -udp-size int\tEDNS0通告的UDP缓冲区大小，设置后自动启用EDNS0 (default 1232)")
+	fmt.Println("    -do\t设置DNSSEC OK (DO) 位，自动启用EDNS0")
+	fmt.Println("    -nsid\t请求服务器标识 (NSID)，自动启用EDNS0")
+	fmt.Println("    -cookie\t附带DNS客户端cookie，自动启用EDNS0")
+	fmt.Println("    -dnssec\t逐区域在本地验证DNSSEC签名链，显示secure/insecure/bogus及上游AD位")
+	fmt.Println("    -cd\t设置Checking Disabled (CD) 位，要求上游不做DNSSEC验证")
+	fmt.Println("    -ttl\t统计各记录在各区域的TTL（最小/中位/最大）和估计缓存时长，并通过迭代解析查询权威TTL，标出高于权威值的区域")
+	fmt.Println("    -follow\t区域只返回CNAME时继续查询链末端的名称，直到得到查询类型的记录")
+	fmt.Println("    -0x20\t对查询名使用DNS 0x20大小写随机化，响应未保留大小写的区域会被标记")
 	fmt.Println()
 	fmt.Println("  Filter options:")
-	fmt.Println("    -re\t显示响应")
+	fmt.Println("    -re\t显示响应：标出每条应答来自哪些区域，并输出区域×应答集合矩阵")
 	fmt.Println("    -ro\t只输出响应值")
+	fmt.Println("    -sections\t显示各区域响应的头部标志（AA/TC/RA/AD/CD）、大小以及权威部分和附加部分")
+	fmt.Println("    -timing\t输出各区域请求耗时汇总表（DNS解析、连接、TLS握手、首字节、总耗时，毫秒）")
 	fmt.Println("    -json\t输出完整JSON格式")
 	fmt.Println()
 	fmt.Println("  Output options:")
@@ -42,8 +72,32 @@ func printUsage() {
 	fmt.Println("    -silent\t静默模式，不显示logo")
 	fmt.Println()
 	fmt.Println("  Other options:")
+	fmt.Println("    -m string\t查询后端 (vercel/cloudflare/doh/doh-json/udp/tcp/dot/doq/iterative) (default vercel，可通过DNS_MODE环境变量修改)")
+	fmt.Println("    -s string\t自定义服务器列表，文件/逗号分隔 (doh/doh-json模式为DoH地址，udp/tcp模式为ip:port，dot/doq模式为host:port#sni，iterative模式为起始根服务器)")
+	fmt.Println("    -method string\tDoH请求方法 (GET/POST) (default GET)")
+	fmt.Println("    -sni string\tDoT/DoQ默认SNI，服务器未指定#sni时使用")
+	fmt.Println("    -proxy string\t代理列表，文件/逗号分隔，格式 [区域=]http://|socks5://[user:pass@]host:port，区域可为DoH地址，仅vercel/cloudflare/doh/doh-json模式")
+	fmt.Println("    -proxy-fails int\t代理连续失败多少次后停用 (default 3)")
+	fmt.Println("    -record string\t录制目录，保存每个区域的原始响应和请求信息")
+	fmt.Println("    -replay string\t回放目录，使用录制的响应代替网络查询")
+	fmt.Println("    -ecs string\tECS地理模拟，逗号分隔的国家代码或all")
+	fmt.Println("    -ecs-table string\t自定义ECS国家子网表文件（每行: 国家代码 子网）")
+	fmt.Println("    -pin string\tDoT/DoQ证书公钥固定，逗号分隔的base64 SPKI SHA-256")
+	fmt.Println("    -mmdb string\tMaxMind/DB-IP MMDB数据库文件，逗号分隔 (City/Country库和ASN库可同时指定)，为A/AAAA应答补充国家、城市、坐标、ASN和组织")
 	fmt.Println("    -r string\tDNS解析器 (alidns/google/cloudflare) (default cloudflare)")
+	fmt.Println("    -surf-url string\tdns.surf接口地址，替换vercel/cloudflare模式的默认地址（如本地模拟服务器），优先于DNS_SURF_URL环境变量")
 	fmt.Println("    -t int\t并发线程数 (default 10)")
+	fmt.Println("    -retries int\t区域查询失败重试次数，0为不重试 (default 0)")
+	fmt.Println("    -backoff duration\t重试基础等待时间，指数退避并带抖动，遵循Retry-After (default 500ms)")
+	fmt.Println("    -max-backoff duration\t单次重试等待上限，同时限制Retry-After (default 5s)")
+	fmt.Println("    -cb-threshold int\t区域连续失败多少次后熔断，0为不熔断 (default 0)")
+	fmt.Println("    -cb-cooldown duration\t熔断冷却时间 (default 30s)")
+	fmt.Println("    -qps float\t全局每秒请求数上限，0为不限制")
+	fmt.Println("    -upstream-qps float\t每个上游服务的每秒请求数上限")
+	fmt.Println("    -region-qps float\t每个区域的每秒请求数上限")
+	fmt.Println("    -zone-qps float\t每个被查询域名所属可注册域的每秒请求数上限")
+	fmt.Println("    -daily-budget int\t每个上游每天的请求数上限，跨运行累计，0为不限制")
+	fmt.Println("    -quota-file string\t每日请求计数文件，每次运行都会累计 (default ~/.geodns/quota.json)")
 	fmt.Println("    -v\t\t详细模式，显示调试信息")
 }
 
@@ -68,10 +122,25 @@ func main() {
 	axfr := flag.Bool("axfr", false, "查询AXFR记录")
 	caa := flag.Bool("caa", false, "查询CAA记录")
 	recon := flag.Bool("recon", false, "查询所有类型")
+	class := flag.String("class", "IN", "查询类 (IN/CH/HS或编号)")
+	identify := flag.Bool("identify", false, "在每个区域查询CH类的version.bind、hostname.bind、id.server，识别解析器软件和实例")
+	typeList := flag.String("type", "", "查询任意记录类型，逗号分隔的类型名或编号 (如 HTTPS,SVCB,TLSA,DS,65)")
+	edns := flag.Bool("edns", false, "添加EDNS0 OPT记录并显示各区域返回的EDNS0信息")
+	udpSize := flag.Uint("udp-size", 0, "EDNS0通告的UDP缓冲区大小，设置后自动启用EDNS0 (默认1232)")
+	dnssecOK := flag.Bool("do", false, "设置DNSSEC OK (DO) 位，自动启用EDNS0")
+	nsid := flag.Bool("nsid", false, "请求服务器标识 (NSID)，自动启用EDNS0")
+	cookie := flag.Bool("cookie", false, "附带DNS客户端cookie，自动启用EDNS0")
+	validate := flag.Bool("dnssec", false, "逐区域在本地验证DNSSEC签名链，显示secure/insecure/bogus及上游AD位")
+	cd := flag.Bool("cd", false, "设置Checking Disabled (CD) 位，要求上游不做DNSSEC验证")
+	ttlAnalysis := flag.Bool("ttl", false, "统计各记录在各区域的TTL（最小/中位/最大）和估计缓存时长，并通过迭代解析查询权威TTL，标出高于权威值的区域")
+	followCNAME := flag.Bool("follow", false, "区域只返回CNAME时继续查询链末端的名称，直到得到查询类型的记录")
+	randomCase := flag.Bool("0x20", false, "对查询名使用DNS 0x20大小写随机化，响应未保留大小写的区域会被标记")
 
 	// Filter
-	showResponse := flag.Bool("re", false, "显示响应")
+	showResponse := flag.Bool("re", false, "显示响应：标出每条应答来自哪些区域，并输出区域×应答集合矩阵")
 	responseOnly := flag.Bool("ro", false, "只输出响应值")
+	showSections := flag.Bool("sections", false, "显示各区域响应的头部标志（AA/TC/RA/AD/CD）、大小以及权威部分和附加部分")
+	showTiming := flag.Bool("timing", false, "输出各区域请求耗时汇总表（DNS解析、连接、TLS握手、首字节、总耗时，毫秒）")
 	jsonOutput := flag.Bool("json", false, "输出完整JSON格式")
 
 	// Output
@@ -79,8 +148,32 @@ func main() {
 	silent := flag.Bool("silent", false, "静默模式，不显示logo")
 
 	// Other
+	mode := flag.String("m", config.Mode, "查询后端 (vercel/cloudflare/doh/doh-json/udp/tcp/dot/doq/iterative)")
+	servers := flag.String("s", "", "自定义服务器列表，文件/逗号分隔 (doh/doh-json模式为DoH地址，udp/tcp模式为ip:port，dot/doq模式为host:port#sni，iterative模式为起始根服务器)")
+	method := flag.String("method", "GET", "DoH请求方法 (GET/POST)")
+	sni := flag.String("sni", "", "DoT/DoQ默认SNI，服务器未指定#sni时使用")
+	proxy := flag.String("proxy", "", "代理列表，文件/逗号分隔，格式 [区域=]http://|socks5://[user:pass@]host:port，区域可为DoH地址")
+	proxyFails := flag.Int("proxy-fails", 3, "代理连续失败多少次后停用")
+	record := flag.String("record", "", "录制目录，保存每个区域的原始响应和请求信息")
+	replay := flag.String("replay", "", "回放目录，使用录制的响应代替网络查询")
+	ecs := flag.String("ecs", "", "ECS地理模拟，逗号分隔的国家代码或all")
+	ecsTable := flag.String("ecs-table", "", "自定义ECS国家子网表文件（每行: 国家代码 子网）")
+	pins := flag.String("pin", "", "DoT/DoQ证书公钥固定，逗号分隔的base64 SPKI SHA-256")
+	mmdb := flag.String("mmdb", "", "MaxMind/DB-IP MMDB数据库文件，逗号分隔 (City/Country库和ASN库可同时指定)，为A/AAAA应答补充国家、城市、坐标、ASN和组织")
 	resolver := flag.String("r", "cloudflare", "DNS解析器 (alidns/google/cloudflare)")
+	surfURL := flag.String("surf-url", "", "dns.surf接口地址，替换vercel/cloudflare模式的默认地址")
 	threads := flag.Int("t", 10, "并发线程数")
+	retries := flag.Int("retries", client.DefaultRetryPolicy.Retries, "区域查询失败重试次数，0为不重试")
+	backoff := flag.Duration("backoff", client.DefaultRetryPolicy.BaseDelay, "重试基础等待时间，指数退避并带抖动，遵循Retry-After")
+	maxBackoff := flag.Duration("max-backoff", client.DefaultRetryPolicy.MaxDelay, "单次重试等待上限，同时限制Retry-After")
+	cbThreshold := flag.Int("cb-threshold", 0, "区域连续失败多少次后熔断，0为不熔断")
+	cbCooldown := flag.Duration("cb-cooldown", 30*time.Second, "熔断冷却时间")
+	qps := flag.Float64("qps", 0, "全局每秒请求数上限，0为不限制")
+	upstreamQPS := flag.Float64("upstream-qps", 0, "每个上游服务的每秒请求数上限")
+	regionQPS := flag.Float64("region-qps", 0, "每个区域的每秒请求数上限")
+	zoneQPS := flag.Float64("zone-qps", 0, "每个被查询域名所属可注册域的每秒请求数上限")
+	dailyBudget := flag.Int("daily-budget", 0, "每个上游每天的请求数上限，跨运行累计，0为不限制")
+	quotaFile := flag.String("quota-file", backend.DefaultQuotaPath(), "每日请求计数文件")
 	verbose := flag.Bool("v", false, "详细模式，显示调试信息")
 
 	flag.Parse()
@@ -99,6 +192,117 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *surfURL != "" {
+		if u, err := url.Parse(*surfURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fmt.Fprintf(os.Stderr, "-surf-url 必须是http或https地址: %s\n", *surfURL)
+			os.Exit(1)
+		}
+		config.SetSurfBaseURL(*surfURL)
+	}
+
+	inputProcessor := input.NewInputProcessor()
+
+	// 创建查询后端
+	var serverList []string
+	if *servers != "" {
+		var err error
+		serverList, err = inputProcessor.GetDomains(*servers)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "服务器列表读取错误: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var proxies *backend.ProxyPool
+	if *proxy != "" {
+		if !slices.Contains(config.HTTPModes, *mode) {
+			fmt.Fprintf(os.Stderr, "-proxy 参数只支持 %s 模式，%s 模式不支持代理\n", strings.Join(config.HTTPModes, "/"), *mode)
+			os.Exit(1)
+		}
+		proxyList, err := inputProcessor.GetDomains(*proxy)
+		if err == nil {
+			proxies, err = backend.NewProxyPool(proxyList, *proxyFails)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "代理设置错误: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *record != "" && *replay != "" {
+		fmt.Fprintln(os.Stderr, "-record 和 -replay 参数不能同时使用")
+		os.Exit(1)
+	}
+	qclass, err := query.ParseClass(*class)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "查询类错误: %v\n", err)
+		os.Exit(1)
+	}
+	// JSON DoH接口没有查询类参数，只能查询IN类
+	if *mode == config.ModeDoHJSON && *replay == "" && (*identify || qclass != dns.ClassINET) {
+		fmt.Fprintln(os.Stderr, "doh-json 模式只支持IN类查询，不能使用 -class 或 -identify")
+		os.Exit(1)
+	}
+
+	var queryBackend backend.Backend
+	if *replay != "" {
+		queryBackend, err = backend.NewReplayBackend(*replay)
+	} else {
+		queryBackend, err = backend.New(backend.Options{
+			Mode:     *mode,
+			Resolver: config.GetResolverCode(),
+			Servers:  serverList,
+			Method:   *method,
+			Proxies:  proxies,
+			TLS: backend.TLSOptions{
+				ServerName: *sni,
+				Pins:       inputProcessor.ParseCommaSeparated(*pins),
+			},
+		})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "查询后端设置错误: %v\n", err)
+		os.Exit(1)
+	}
+	// 回放时区域列表已包含录制时的ECS虚拟区域
+	if *ecs != "" && *replay == "" {
+		table := config.DefaultECSSubnets
+		if *ecsTable != "" {
+			lines, err := inputProcessor.ReadFromFile(*ecsTable)
+			if err == nil {
+				table, err = backend.ParseECSTable(lines)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ECS子网表读取错误: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		queryBackend, err = backend.NewECSBackend(queryBackend, inputProcessor.ParseCommaSeparated(*ecs), table)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ECS设置错误: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *record != "" {
+		queryBackend, err = backend.NewRecordingBackend(queryBackend, *record)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "录制目录设置错误: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	limits := backend.RateLimits{Global: *qps, Upstream: *upstreamQPS, Region: *regionQPS, Zone: *zoneQPS}
+	// 每日请求数始终累计到计数文件，-daily-budget只决定是否拦截；回放不发出请求，不计数
+	var quota *backend.Quota
+	if *replay == "" {
+		quota, err = backend.LoadQuota(*quotaFile, *dailyBudget)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "请求计数文件读取错误: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	queryBackend = backend.NewRateLimitedBackend(queryBackend, limits, quota)
+	if config.IsVerbose() {
+		log.Printf("Using backend: %s (%d regions)", queryBackend.Name(), len(queryBackend.Regions()))
+	}
+
 	// 输入参数校验
 	if *inputFile != "" && *domainArg != "" {
 		fmt.Fprintln(os.Stderr, "-l 和 -d 参数不能同时使用")
@@ -106,14 +310,14 @@ func main() {
 	}
 
 	var domains []string
-	var err error
-	inputProcessor := input.NewInputProcessor()
 
 	// 检查是否有标准输入
 	stat, _ := os.Stdin.Stat()
 	hasStdin := (stat.Mode() & os.ModeCharDevice) == 0
 
-	if *inputFile != "" {
+	if *identify {
+		// 识别模式使用内置的查询名，不需要域名输入
+	} else if *inputFile != "" {
 		domains, err = inputProcessor.ReadFromFile(*inputFile)
 	} else if *domainArg != "" {
 		domains, err = inputProcessor.GetDomains(*domainArg)
@@ -171,11 +375,87 @@ func main() {
 			recordTypes = append(recordTypes, 257)
 		}
 	}
+	for _, name := range inputProcessor.ParseCommaSeparated(*typeList) {
+		rt, err := query.ParseType(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "记录类型错误: %v\n", err)
+			os.Exit(1)
+		}
+		if !slices.Contains(recordTypes, rt) {
+			recordTypes = append(recordTypes, rt)
+		}
+	}
 	if len(recordTypes) == 0 {
 		recordTypes = append(recordTypes, 1) // 默认A记录
 	}
 
-	dnsService := service.NewDNSQueryService(*jsonOutput, *responseOnly, *showResponse, *threads, recordTypes, *outputFile)
+	retryPolicy := client.DefaultRetryPolicy
+	retryPolicy.Retries = *retries
+	retryPolicy.BaseDelay = *backoff
+	retryPolicy.MaxDelay = *maxBackoff
+	queryClient := client.NewClient(queryBackend, retryPolicy, client.NewCircuitBreaker(*cbThreshold, *cbCooldown))
+	if *validate {
+		queryClient.SetValidator(dnssec.NewValidator(queryBackend))
+	}
+	queryClient.SetFollowCNAME(*followCNAME)
+	if *mmdb != "" {
+		geoDB, err := geoip.Open(inputProcessor.ParseCommaSeparated(*mmdb))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "MMDB数据库打开错误: %v\n", err)
+			os.Exit(1)
+		}
+		queryClient.SetGeoIP(geoDB)
+	}
+
+	if *udpSize > 65535 {
+		fmt.Fprintln(os.Stderr, "-udp-size 不能大于65535")
+		os.Exit(1)
+	}
+
+	dnsService := service.NewDNSQueryService(queryClient, *jsonOutput, *responseOnly, *showResponse, *threads, recordTypes, *outputFile)
+	dnsService.SetEDNS(query.EDNSOptions{
+		Enabled: *edns,
+		UDPSize: uint16(*udpSize),
+		DO:      *dnssecOK,
+		NSID:    *nsid,
+		Cookie:  *cookie,
+	})
+	dnsService.SetCheckingDisabled(*cd)
+	dnsService.SetRandomCase(*randomCase)
+	dnsService.SetShowSections(*showSections)
+	dnsService.SetShowTiming(*showTiming)
+	if *ttlAnalysis {
+		// 权威TTL查询随主查询一起录制到子目录中，回放时从子目录读取，不访问网络
+		var refBackend backend.Backend
+		if *replay != "" {
+			refBackend, err = backend.NewReplayBackend(filepath.Join(*replay, ttlRecordDir))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "回放目录中没有权威TTL查询的录制（录制时需使用 -ttl）: %v\n", err)
+				os.Exit(1)
+			}
+		} else {
+			// 迭代模式下沿用相同的起始服务器，否则从内置根服务器开始
+			var roots []string
+			if *mode == config.ModeIterative {
+				roots = serverList
+			}
+			refBackend, err = backend.NewIterativeBackend(roots)
+			if err == nil && *record != "" {
+				refBackend, err = backend.NewRecordingBackend(refBackend, filepath.Join(*record, ttlRecordDir))
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "权威TTL查询设置错误: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		dnsService.SetTTLReference(client.NewClient(refBackend, retryPolicy, nil))
+	}
+	if *identify {
+		dnsService.Identify()
+		dnsService.Close()
+		return
+	}
+	dnsService.SetClass(qclass)
 	dnsService.QueryMultiple(domains, recordTypes)
 	dnsService.Close()
 }
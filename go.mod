@@ -5,9 +5,17 @@ go 1.23.0
 toolchain go1.23.10
 
 require (
-	github.com/miekg/dns v1.1.66 // indirect
+	github.com/miekg/dns v1.1.66
+	github.com/oschwald/maxminddb-golang v1.13.1
+	github.com/quic-go/quic-go v0.54.1
+	golang.org/x/net v0.39.0
+	golang.org/x/time v0.11.0
+)
+
+require (
+	go.uber.org/mock v0.5.0 // indirect
+	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/mod v0.24.0 // indirect
-	golang.org/x/net v0.39.0 // indirect
 	golang.org/x/sync v0.13.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/tools v0.32.0 // indirect
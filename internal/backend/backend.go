@@ -0,0 +1,99 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/JaveleyQAQ/geodns/internal/config"
+	"github.com/miekg/dns"
+)
+
+// Backend DNS查询后端，负责把查询报文发送到某个区域并取回原始响应
+type Backend interface {
+	// Name 后端描述，用于日志和输出
+	Name() string
+	// Regions 后端可查询的区域列表
+	Regions() []string
+	// Exchange 向指定区域发送查询，返回wire格式的原始响应
+	Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error)
+}
+
+// Upstreamer 可选接口：报告区域的请求实际发往的上游主机，用于按上游限速和累计每日配额
+type Upstreamer interface {
+	Upstream(region string) string
+}
+
+// Options 后端构建参数
+type Options struct {
+	Mode     string              // 后端模式
+	Resolver string              // dns.surf 解析器代码（仅vercel模式使用）
+	Provider *config.DNSProvider // dns.surf 地址和区域，为空时使用内置配置（仅vercel/cloudflare模式使用）
+	Servers  []string            // 自定义服务器列表（DoH地址、域名服务器等），每个服务器作为一个区域
+	Method   string              // DoH 请求方法 (GET/POST)
+	TLS      TLSOptions          // DoT/DoQ 的TLS参数
+	Client   *http.Client        // HTTP类后端使用的客户端，为空时使用默认配置
+	Proxies  *ProxyPool          // HTTP类后端使用的代理池，Client为空时生效
+}
+
+// New 根据模式创建查询后端
+func New(opts Options) (Backend, error) {
+	client := opts.Client
+	if client == nil {
+		client = NewHTTPClient(opts.Proxies)
+	}
+
+	switch opts.Mode {
+	case config.ModeVercel:
+		provider := opts.Provider
+		if provider == nil {
+			provider = config.VercelProvider
+		}
+		return NewVercelBackend(provider, opts.Resolver, client), nil
+	case config.ModeCloudflare:
+		provider := opts.Provider
+		if provider == nil {
+			provider = config.CloudflareProvider
+		}
+		return NewCloudflareBackend(provider, client), nil
+	case config.ModeDoH:
+		b, err := NewDoHBackend(opts.Servers, opts.Method, client)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	case config.ModeDoHJSON:
+		b, err := NewDoHJSONBackend(opts.Servers, client)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	case config.ModeUDP, config.ModeTCP:
+		b, err := NewPlainBackend(opts.Servers, opts.Mode)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	case config.ModeDoT:
+		b, err := NewDoTBackend(opts.Servers, opts.TLS)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	case config.ModeDoQ:
+		b, err := NewDoQBackend(opts.Servers, opts.TLS)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	case config.ModeIterative:
+		b, err := NewIterativeBackend(opts.Servers)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unsupported mode: %s. Supported: %s", opts.Mode, strings.Join(config.Modes, ", "))
+	}
+}
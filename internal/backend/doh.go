@@ -0,0 +1,101 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/JaveleyQAQ/geodns/internal/query"
+	"github.com/miekg/dns"
+)
+
+// DoH 请求方法
+const (
+	DoHMethodGet  = "GET"
+	DoHMethodPost = "POST"
+)
+
+// dnsMessageType RFC 8484 报文媒体类型
+const dnsMessageType = "application/dns-message"
+
+// DoHBackend RFC 8484 DNS-over-HTTPS 后端，每个DoH地址作为一个区域
+type DoHBackend struct {
+	endpoints []string
+	method    string
+	client    *http.Client
+}
+
+// NewDoHBackend 创建DoH后端
+func NewDoHBackend(endpoints []string, method string, client *http.Client) (*DoHBackend, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("doh backend requires at least one server URL")
+	}
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("invalid DoH URL: %s", endpoint)
+		}
+	}
+
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = DoHMethodGet
+	}
+	if method != DoHMethodGet && method != DoHMethodPost {
+		return nil, fmt.Errorf("unsupported DoH method: %s. Supported: GET, POST", method)
+	}
+
+	return &DoHBackend{
+		endpoints: endpoints,
+		method:    method,
+		client:    client,
+	}, nil
+}
+
+func (b *DoHBackend) Name() string {
+	return fmt.Sprintf("doh (%s)", b.method)
+}
+
+func (b *DoHBackend) Regions() []string {
+	return b.endpoints
+}
+
+func (b *DoHBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	// RFC 8484 建议使用ID 0，以便HTTP缓存命中
+	msg.Id = 0
+
+	var req *http.Request
+	if b.method == DoHMethodPost {
+		buf, err := msg.Pack()
+		if err != nil {
+			return nil, err
+		}
+		req, err = newRequest(ctx, region, http.MethodPost, region, bytes.NewReader(buf))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", dnsMessageType)
+	} else {
+		encodedQuery, err := query.Encode(msg)
+		if err != nil {
+			return nil, err
+		}
+		u, err := url.Parse(region)
+		if err != nil {
+			return nil, err
+		}
+		params := u.Query()
+		params.Set("dns", encodedQuery)
+		u.RawQuery = params.Encode()
+		req, err = newRequest(ctx, region, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	req.Header.Set("Accept", dnsMessageType)
+
+	return doRequest(b.client, req)
+}
@@ -0,0 +1,92 @@
+package backend
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// startDoHServer 启动本地DoH服务器，记录收到的请求方法和查询报文，对所有查询返回一条A记录
+func startDoHServer(t *testing.T, got func(method, contentType string, query *dns.Msg)) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var wire []byte
+		var err error
+		if r.Method == http.MethodPost {
+			wire, err = io.ReadAll(r.Body)
+		} else {
+			wire, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		}
+		query := new(dns.Msg)
+		if err == nil {
+			err = query.Unpack(wire)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		got(r.Method, r.Header.Get("Content-Type"), query)
+
+		m := new(dns.Msg)
+		m.SetReply(query)
+		rr, _ := dns.NewRR(query.Question[0].Name + " 60 IN A 192.0.2.1")
+		m.Answer = append(m.Answer, rr)
+		resp, _ := m.Pack()
+		w.Header().Set("Content-Type", dnsMessageType)
+		w.Write(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/dns-query"
+}
+
+func TestDoHMethods(t *testing.T) {
+	for _, method := range []string{DoHMethodGet, DoHMethodPost} {
+		t.Run(method, func(t *testing.T) {
+			var gotMethod, gotType string
+			var gotQuery *dns.Msg
+			url := startDoHServer(t, func(method, contentType string, query *dns.Msg) {
+				gotMethod, gotType, gotQuery = method, contentType, query
+			})
+			b, err := NewDoHBackend([]string{url}, method, http.DefaultClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, err := exchangeA(t, b, url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotMethod != method {
+				t.Errorf("method = %s, want %s", gotMethod, method)
+			}
+			if method == DoHMethodPost && gotType != dnsMessageType {
+				t.Errorf("content type = %q, want %q", gotType, dnsMessageType)
+			}
+			// RFC 8484 建议使用ID 0，便于HTTP缓存
+			if gotQuery.Id != 0 {
+				t.Errorf("query id = %d, want 0", gotQuery.Id)
+			}
+			if gotQuery.Question[0].Name != "example.com." || gotQuery.Question[0].Qtype != dns.TypeA {
+				t.Errorf("question = %v", gotQuery.Question[0])
+			}
+
+			resp := new(dns.Msg)
+			if err := resp.Unpack(body); err != nil || len(resp.Answer) != 1 {
+				t.Errorf("response = %v, err = %v", resp, err)
+			}
+		})
+	}
+}
+
+func TestDoHRejectsBadConfig(t *testing.T) {
+	if _, err := NewDoHBackend([]string{"dns.google/dns-query"}, "", http.DefaultClient); err == nil {
+		t.Error("URL without scheme accepted")
+	}
+	if _, err := NewDoHBackend([]string{"https://dns.google/dns-query"}, "PUT", http.DefaultClient); err == nil {
+		t.Error("PUT method accepted")
+	}
+}
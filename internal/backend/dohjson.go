@@ -0,0 +1,269 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/JaveleyQAQ/geodns/internal/config"
+	"github.com/miekg/dns"
+)
+
+// dnsJSONType JSON DoH API 媒体类型
+const dnsJSONType = "application/dns-json"
+
+// JSONResponse Google/Cloudflare 风格的JSON DoH响应
+type JSONResponse struct {
+	Status     int          `json:"Status"`
+	TC         bool         `json:"TC"`
+	RD         bool         `json:"RD"`
+	RA         bool         `json:"RA"`
+	AD         bool         `json:"AD"`
+	CD         bool         `json:"CD"`
+	Question   []JSONRecord `json:"Question"`
+	Answer     []JSONRecord `json:"Answer"`
+	Authority  []JSONRecord `json:"Authority"`
+	Additional []JSONRecord `json:"Additional"`
+	Comment    interface{}  `json:"Comment,omitempty"`
+	// EDNSClientSubnet 请求带ECS时解析器返回的 "地址/scope"
+	EDNSClientSubnet string `json:"edns_client_subnet,omitempty"`
+}
+
+// JSONRecord JSON DoH响应中的单条记录
+type JSONRecord struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32 `json:"TTL"`
+	Data string `json:"data"`
+}
+
+// DoHJSONBackend JSON DoH API 后端，每个地址作为一个区域，响应转换为wire格式以便与其他后端结果互通
+type DoHJSONBackend struct {
+	endpoints []string
+	client    *http.Client
+}
+
+// NewDoHJSONBackend 创建JSON DoH后端
+func NewDoHJSONBackend(endpoints []string, client *http.Client) (*DoHJSONBackend, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("doh-json backend requires at least one server URL")
+	}
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("invalid DoH URL: %s", endpoint)
+		}
+	}
+	return &DoHJSONBackend{
+		endpoints: endpoints,
+		client:    client,
+	}, nil
+}
+
+func (b *DoHJSONBackend) Name() string {
+	return config.ModeDoHJSON
+}
+
+func (b *DoHJSONBackend) Regions() []string {
+	return b.endpoints
+}
+
+func (b *DoHJSONBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	if len(msg.Question) == 0 {
+		return nil, fmt.Errorf("query has no question")
+	}
+	q := msg.Question[0]
+	// JSON接口没有查询类参数，只能查询IN类
+	if q.Qclass != dns.ClassINET {
+		return nil, fmt.Errorf("JSON DoH only supports class IN, not %s", dns.Class(q.Qclass))
+	}
+
+	u, err := url.Parse(region)
+	if err != nil {
+		return nil, err
+	}
+	params := u.Query()
+	params.Set("name", q.Name)
+	params.Set("type", strconv.Itoa(int(q.Qtype)))
+	if msg.CheckingDisabled {
+		params.Set("cd", "1")
+	}
+	if opt := msg.IsEdns0(); opt != nil && opt.Do() {
+		params.Set("do", "1")
+	}
+	ecs := clientSubnet(msg)
+	if ecs != nil {
+		params.Set("edns_client_subnet", fmt.Sprintf("%s/%d", ecs.Address, ecs.SourceNetmask))
+	}
+	u.RawQuery = params.Encode()
+
+	req, err := newRequest(ctx, region, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", dnsJSONType)
+
+	body, err := doRequest(b.client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var jr JSONResponse
+	if err := json.Unmarshal(body, &jr); err != nil {
+		return nil, fmt.Errorf("%w: invalid JSON DoH response: %v", ErrMalformed, err)
+	}
+	resp, err := jr.Msg()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	resp.Id = msg.Id
+	if len(resp.Question) == 0 {
+		resp.Question = msg.Question
+	}
+	if ecs != nil {
+		if scope, ok := jr.subnetScope(); ok {
+			// JSON响应只带scope，按发送的子网还原ECS选项，与wire格式后端的结果一致
+			opt := resp.IsEdns0()
+			if opt == nil {
+				resp.SetEdns0(dns.DefaultMsgSize, false)
+				opt = resp.IsEdns0()
+			}
+			opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{
+				Code:          dns.EDNS0SUBNET,
+				Family:        ecs.Family,
+				SourceNetmask: ecs.SourceNetmask,
+				SourceScope:   scope,
+				Address:       ecs.Address,
+			})
+		}
+	}
+	return resp.Pack()
+}
+
+// clientSubnet 返回查询中的ECS选项，没有时返回nil
+func clientSubnet(msg *dns.Msg) *dns.EDNS0_SUBNET {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		if ecs, ok := o.(*dns.EDNS0_SUBNET); ok {
+			return ecs
+		}
+	}
+	return nil
+}
+
+// subnetScope 解析 edns_client_subnet 字段的scope前缀长度
+func (jr *JSONResponse) subnetScope() (uint8, bool) {
+	_, scope, ok := strings.Cut(jr.EDNSClientSubnet, "/")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(scope, 10, 8)
+	if err != nil || n > 128 {
+		return 0, false
+	}
+	return uint8(n), true
+}
+
+// Msg 将JSON响应转换为DNS报文，存在无法解析的记录时返回错误
+func (jr *JSONResponse) Msg() (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.Response = true
+	msg.Rcode = jr.Status
+	msg.Truncated = jr.TC
+	msg.RecursionDesired = jr.RD
+	msg.RecursionAvailable = jr.RA
+	msg.AuthenticatedData = jr.AD
+	msg.CheckingDisabled = jr.CD
+
+	for _, q := range jr.Question {
+		msg.Question = append(msg.Question, dns.Question{
+			Name:   dns.Fqdn(q.Name),
+			Qtype:  q.Type,
+			Qclass: dns.ClassINET,
+		})
+	}
+	var errs []error
+	msg.Answer = jsonRecordsToRRs(jr.Answer, &errs)
+	msg.Ns = jsonRecordsToRRs(jr.Authority, &errs)
+	msg.Extra = jsonRecordsToRRs(jr.Additional, &errs)
+	if len(errs) > 0 {
+		return msg, fmt.Errorf("%d JSON records could not be parsed: %w", len(errs), errors.Join(errs...))
+	}
+	return msg, nil
+}
+
+// jsonRecordsToRRs 将JSON记录转换为资源记录，无法解析的记录跳过并把错误追加到errs
+func jsonRecordsToRRs(records []JSONRecord, errs *[]error) []dns.RR {
+	var rrs []dns.RR
+	for _, r := range records {
+		rr, err := jsonRecordToRR(r)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s (type %d): %w", r.Name, r.Type, err))
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs
+}
+
+// jsonRecordToRR 用记录的表示格式构建资源记录
+func jsonRecordToRR(r JSONRecord) (dns.RR, error) {
+	typeName, ok := dns.TypeToString[r.Type]
+	if !ok {
+		typeName = fmt.Sprintf("TYPE%d", r.Type)
+	}
+
+	// 部分服务（如Google）返回的TXT数据是不带引号的原始文本，不是表示格式，直接构建记录
+	if (r.Type == dns.TypeTXT || r.Type == dns.TypeSPF) && !strings.HasPrefix(r.Data, "\"") {
+		hdr := dns.RR_Header{Name: dns.Fqdn(r.Name), Rrtype: r.Type, Class: dns.ClassINET, Ttl: r.TTL}
+		if r.Type == dns.TypeSPF {
+			return &dns.SPF{Hdr: hdr, Txt: splitTXT(r.Data)}, nil
+		}
+		return &dns.TXT{Hdr: hdr, Txt: splitTXT(r.Data)}, nil
+	}
+
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(r.Name), r.TTL, typeName, r.Data))
+	if err != nil {
+		return nil, err
+	}
+	if rr == nil {
+		return nil, fmt.Errorf("empty record data")
+	}
+	return rr, nil
+}
+
+// splitTXT 将原始文本按255字节拆分为TXT字符串，并转换为miekg/dns使用的表示格式转义
+func splitTXT(s string) []string {
+	var parts []string
+	for len(s) > 255 {
+		parts = append(parts, escapeTXT(s[:255]))
+		s = s[255:]
+	}
+	return append(parts, escapeTXT(s))
+}
+
+// escapeTXT 按RFC 1035表示格式转义：引号和反斜杠加反斜杠，不可打印字节和非ASCII字节为 \DDD
+func escapeTXT(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
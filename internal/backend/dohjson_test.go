@@ -0,0 +1,147 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+func TestDoHJSONRejectsNonINClass(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", dnsJSONType)
+		w.Write([]byte(`{"Status":0,"Question":[{"name":"version.bind.","type":16}]}`))
+	}))
+	defer srv.Close()
+
+	b, err := NewDoHJSONBackend([]string{srv.URL}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := new(dns.Msg)
+	m.SetQuestion("version.bind.", dns.TypeTXT)
+	m.Question[0].Qclass = dns.ClassCHAOS
+	if _, err := b.Exchange(context.Background(), srv.URL, m); err == nil {
+		t.Error("CH query succeeded, want an error")
+	}
+	if calls != 0 {
+		t.Errorf("sent %d requests for a CH query, want none", calls)
+	}
+}
+
+func TestJSONResponseMsg(t *testing.T) {
+	jr := JSONResponse{
+		Status: dns.RcodeNameError,
+		RD:     true,
+		RA:     true,
+		AD:     true,
+		Question: []JSONRecord{
+			{Name: "example.com", Type: dns.TypeMX},
+		},
+		Answer: []JSONRecord{
+			{Name: "example.com.", Type: dns.TypeMX, TTL: 300, Data: "10 mail.example.com."},
+			{Name: "example.com.", Type: 65534, TTL: 60, Data: `\# 2 0102`},
+		},
+		Authority: []JSONRecord{
+			{Name: "example.com.", Type: dns.TypeSOA, TTL: 900, Data: "ns1.example.com. hostmaster.example.com. 7 3600 600 86400 300"},
+		},
+	}
+	msg, err := jr.Msg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Rcode != dns.RcodeNameError || !msg.Response || !msg.RecursionDesired || !msg.RecursionAvailable || !msg.AuthenticatedData {
+		t.Errorf("header = %+v", msg.MsgHdr)
+	}
+	if len(msg.Question) != 1 || msg.Question[0].Name != "example.com." || msg.Question[0].Qtype != dns.TypeMX {
+		t.Errorf("question = %v", msg.Question)
+	}
+	if len(msg.Answer) != 2 {
+		t.Fatalf("answer = %v", msg.Answer)
+	}
+	if mx, ok := msg.Answer[0].(*dns.MX); !ok || mx.Preference != 10 || mx.Mx != "mail.example.com." || mx.Hdr.Ttl != 300 {
+		t.Errorf("MX = %v", msg.Answer[0])
+	}
+	if rfc3597, ok := msg.Answer[1].(*dns.RFC3597); !ok || rfc3597.Rdata != "0102" {
+		t.Errorf("unknown type = %v", msg.Answer[1])
+	}
+	if len(msg.Ns) != 1 {
+		t.Fatalf("authority = %v", msg.Ns)
+	}
+	if soa, ok := msg.Ns[0].(*dns.SOA); !ok || soa.Serial != 7 || soa.Minttl != 300 {
+		t.Errorf("SOA = %v", msg.Ns[0])
+	}
+}
+
+func TestJSONResponseTXT(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		// Cloudflare返回带引号的表示格式
+		{"quoted", `"v=spf1 -all" "second"`, []string{"v=spf1 -all", "second"}},
+		{"quoted escape", `"caf\195\169"`, []string{`caf\195\169`}},
+		// Google返回原始文本，引号、反斜杠和非ASCII字节需要转义为表示格式
+		{"raw", `say "hi" \o/`, []string{`say \"hi\" \\o/`}},
+		{"raw utf-8", "café\x01", []string{`caf\195\169\001`}},
+		{"raw long", long, []string{long[:255], long[255:]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jr := JSONResponse{Answer: []JSONRecord{{Name: "example.com.", Type: dns.TypeTXT, TTL: 60, Data: tt.data}}}
+			msg, err := jr.Msg()
+			if err != nil {
+				t.Fatal(err)
+			}
+			txt, ok := msg.Answer[0].(*dns.TXT)
+			if !ok || !slices.Equal(txt.Txt, tt.want) {
+				t.Errorf("TXT = %q, want %q", msg.Answer[0], tt.want)
+			}
+			// wire格式往返后内容不变
+			wire, err := msg.Pack()
+			if err != nil {
+				t.Fatal(err)
+			}
+			unpacked := new(dns.Msg)
+			if err := unpacked.Unpack(wire); err != nil {
+				t.Fatal(err)
+			}
+			if got := unpacked.Answer[0].(*dns.TXT).Txt; !slices.Equal(got, tt.want) {
+				t.Errorf("TXT after wire round trip = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONResponseRejectsBadRecords(t *testing.T) {
+	jr := JSONResponse{Answer: []JSONRecord{
+		{Name: "example.com.", Type: dns.TypeA, TTL: 60, Data: "192.0.2.1"},
+		{Name: "example.com.", Type: dns.TypeA, TTL: 60, Data: "not-an-ip"},
+	}}
+	if _, err := jr.Msg(); err == nil {
+		t.Error("unparseable record was dropped silently")
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", dnsJSONType)
+		w.Write([]byte(`{"Status":0,"Answer":[{"name":"example.com.","type":1,"TTL":60,"data":"not-an-ip"}]}`))
+	}))
+	defer srv.Close()
+	b, err := NewDoHJSONBackend([]string{srv.URL}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = exchangeA(t, b, srv.URL)
+	if class, _ := ClassifyError(err); class != types.ErrorMalformed {
+		t.Errorf("error class = %s, want %s (%v)", class, types.ErrorMalformed, err)
+	}
+}
@@ -0,0 +1,172 @@
+package backend
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// doqNoError RFC 9250 DOQ_NO_ERROR
+const doqNoError = 0
+
+// DoQBackend RFC 9250 DNS-over-QUIC 后端，每个服务器保持一个QUIC连接，每次查询使用独立的流
+type DoQBackend struct {
+	regions []string
+	servers map[string]*encryptedServer
+	conns   map[string]*doqConn // 区域 -> 连接，构造后不再增删
+}
+
+// doqConn 区域的QUIC连接，各区域独立加锁，拨号不会阻塞其他区域
+type doqConn struct {
+	mu   sync.Mutex
+	conn *quic.Conn
+}
+
+// NewDoQBackend 创建DoQ后端
+func NewDoQBackend(servers []string, opts TLSOptions) (*DoQBackend, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("doq backend requires at least one server")
+	}
+	regions, configs, err := parseEncryptedServers(servers, "853", []string{"doq"}, opts)
+	if err != nil {
+		return nil, err
+	}
+	conns := make(map[string]*doqConn, len(regions))
+	for _, region := range regions {
+		conns[region] = &doqConn{}
+	}
+	return &DoQBackend{
+		regions: regions,
+		servers: configs,
+		conns:   conns,
+	}, nil
+}
+
+func (b *DoQBackend) Name() string {
+	return "doq"
+}
+
+func (b *DoQBackend) Regions() []string {
+	return b.regions
+}
+
+func (b *DoQBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	server, ok := b.servers[region]
+	if !ok {
+		return nil, fmt.Errorf("unknown region: %s", region)
+	}
+
+	// RFC 9250 要求报文ID为0
+	msg.Id = 0
+	buf, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+	// 每个报文前加2字节长度
+	packet := make([]byte, 2+len(buf))
+	binary.BigEndian.PutUint16(packet, uint16(len(buf)))
+	copy(packet[2:], buf)
+
+	conn, reused, err := b.conn(ctx, region, server)
+	if err != nil {
+		return nil, err
+	}
+	body, err := exchangeStream(ctx, conn, packet)
+	if err != nil && reused && ctx.Err() == nil {
+		// 复用的连接可能已被服务器关闭，重新建立后重试一次
+		b.drop(region, conn)
+		if conn, _, err = b.conn(ctx, region, server); err != nil {
+			return nil, err
+		}
+		body, err = exchangeStream(ctx, conn, packet)
+	}
+	return body, err
+}
+
+// exchangeStream 在新的流上发送一个报文，发送完成后关闭写方向并读取应答
+func exchangeStream(ctx context.Context, conn *quic.Conn, packet []byte) ([]byte, error) {
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CancelRead(doqNoError)
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(10 * time.Second)
+	}
+	stream.SetDeadline(deadline)
+
+	if _, err := stream.Write(packet); err != nil {
+		return nil, err
+	}
+	if err := stream.Close(); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(stream, length[:]); err != nil {
+		return nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(stream, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// conn 获取区域的QUIC连接，不存在或已关闭时重新建立，reused表示返回的是已有连接
+func (b *DoQBackend) conn(ctx context.Context, region string, server *encryptedServer) (*quic.Conn, bool, error) {
+	slot := b.conns[region]
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+
+	if slot.conn != nil {
+		select {
+		case <-slot.conn.Context().Done():
+			slot.conn = nil
+		default:
+			return slot.conn, true, nil
+		}
+	}
+
+	conn, err := quic.DialAddr(ctx, server.addr, server.tlsConfig, &quic.Config{
+		HandshakeIdleTimeout: 10 * time.Second,
+		MaxIdleTimeout:       30 * time.Second,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	slot.conn = conn
+	return conn, false, nil
+}
+
+// drop 丢弃失效的连接
+func (b *DoQBackend) drop(region string, conn *quic.Conn) {
+	slot := b.conns[region]
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	if slot.conn == conn {
+		slot.conn = nil
+	}
+	conn.CloseWithError(doqNoError, "")
+}
+
+// Close 关闭所有QUIC连接
+func (b *DoQBackend) Close() error {
+	for _, slot := range b.conns {
+		slot.mu.Lock()
+		if slot.conn != nil {
+			slot.conn.CloseWithError(doqNoError, "")
+			slot.conn = nil
+		}
+		slot.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// doqServer 本地DoQ服务器，对所有查询返回一条A记录
+type doqServer struct {
+	ln       *quic.Listener
+	accepted atomic.Int32
+
+	mu    sync.Mutex
+	conns []*quic.Conn
+}
+
+func startDoQServer(t *testing.T, cfg *tls.Config) *doqServer {
+	t.Helper()
+	ln, err := quic.ListenAddr("127.0.0.1:0", cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &doqServer{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			s.accepted.Add(1)
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serveConn(conn)
+		}
+	}()
+	return s
+}
+
+func (s *doqServer) serveConn(conn *quic.Conn) {
+	for {
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		go func() {
+			defer stream.Close()
+			var length [2]byte
+			if _, err := io.ReadFull(stream, length[:]); err != nil {
+				return
+			}
+			buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err := io.ReadFull(stream, buf); err != nil {
+				return
+			}
+			query := new(dns.Msg)
+			if err := query.Unpack(buf); err != nil {
+				return
+			}
+			m := new(dns.Msg)
+			m.SetReply(query)
+			rr, _ := dns.NewRR(query.Question[0].Name + " 60 IN A 192.0.2.1")
+			m.Answer = append(m.Answer, rr)
+			reply, _ := m.Pack()
+			packet := make([]byte, 2+len(reply))
+			binary.BigEndian.PutUint16(packet, uint16(len(reply)))
+			copy(packet[2:], reply)
+			stream.Write(packet)
+		}()
+	}
+}
+
+// closeAll 由服务器关闭所有已建立的连接
+func (s *doqServer) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.CloseWithError(0, "server going away")
+	}
+	s.conns = nil
+}
+
+func TestDoQSNIAndPins(t *testing.T) {
+	cert, pin := testCert(t)
+	var sni sniRecorder
+	srv := startDoQServer(t, sni.serverConfig(cert, "doq"))
+	addr := srv.ln.Addr().String()
+
+	tests := []struct {
+		name    string
+		server  string
+		opts    TLSOptions
+		wantSNI string
+		wantErr bool
+	}{
+		{"default sni", addr, TLSOptions{ServerName: "dns.test", Pins: []string{pin}}, "dns.test", false},
+		{"per-server sni", addr + "#other.test", TLSOptions{ServerName: "dns.test", Pins: []string{pin}}, "other.test", false},
+		{"wrong pin", addr + "#dns.test", TLSOptions{Pins: []string{wrongPin}}, "dns.test", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewDoQBackend([]string{tt.server}, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+
+			body, err := exchangeA(t, b, b.Regions()[0])
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected TLS verification error")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp := new(dns.Msg)
+				if err := resp.Unpack(body); err != nil || len(resp.Answer) != 1 {
+					t.Fatalf("unexpected response: %v %v", resp, err)
+				}
+			}
+			if got := sni.last(); got != tt.wantSNI {
+				t.Errorf("SNI = %q, want %q", got, tt.wantSNI)
+			}
+		})
+	}
+}
+
+func TestDoQRedialsAfterConnectionDies(t *testing.T) {
+	cert, pin := testCert(t)
+	var sni sniRecorder
+	srv := startDoQServer(t, sni.serverConfig(cert, "doq"))
+
+	b, err := NewDoQBackend([]string{srv.ln.Addr().String() + "#dns.test"}, TLSOptions{Pins: []string{pin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	region := b.Regions()[0]
+
+	if _, err := exchangeA(t, b, region); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exchangeA(t, b, region); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.accepted.Load(); got != 1 {
+		t.Fatalf("accepted %d connections before close, want 1", got)
+	}
+
+	srv.closeAll()
+	if _, err := exchangeA(t, b, region); err != nil {
+		t.Fatalf("query after server closed the connection: %v", err)
+	}
+	if got := srv.accepted.Load(); got != 2 {
+		t.Errorf("accepted %d connections, want 2", got)
+	}
+}
+
+func TestDoQDialDoesNotBlockOtherRegions(t *testing.T) {
+	cert, pin := testCert(t)
+	var sni sniRecorder
+	srv := startDoQServer(t, sni.serverConfig(cert, "doq"))
+
+	// 第一个服务器只收包不响应，握手会一直等到超时
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	blackhole := silent.LocalAddr().String() + "#dns.test"
+	b, err := NewDoQBackend([]string{blackhole, srv.ln.Addr().String() + "#dns.test"}, TLSOptions{Pins: []string{pin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	go func() {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		b.Exchange(ctx, b.Regions()[0], m)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := exchangeA(t, b, b.Regions()[1]); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("query to a reachable server took %v while another region was dialing", elapsed)
+	}
+}
@@ -0,0 +1,118 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// maxIdleConns 每个DoT服务器保留的最大空闲连接数
+const maxIdleConns = 16
+
+// DoTBackend RFC 7858 DNS-over-TLS 后端，每个服务器作为一个区域，连接在并发查询间复用
+type DoTBackend struct {
+	regions []string
+	servers map[string]*encryptedServer
+
+	mu   sync.Mutex
+	idle map[string][]*dns.Conn
+}
+
+// NewDoTBackend 创建DoT后端
+func NewDoTBackend(servers []string, opts TLSOptions) (*DoTBackend, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("dot backend requires at least one server")
+	}
+	regions, configs, err := parseEncryptedServers(servers, "853", []string{"dot"}, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &DoTBackend{
+		regions: regions,
+		servers: configs,
+		idle:    make(map[string][]*dns.Conn),
+	}, nil
+}
+
+func (b *DoTBackend) Name() string {
+	return "dot"
+}
+
+func (b *DoTBackend) Regions() []string {
+	return b.regions
+}
+
+func (b *DoTBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	server, ok := b.servers[region]
+	if !ok {
+		return nil, fmt.Errorf("unknown region: %s", region)
+	}
+	client := &dns.Client{Net: "tcp-tls", TLSConfig: server.tlsConfig, Timeout: 10 * time.Second}
+
+	// 空闲连接可能已被服务器关闭或残留之前查询的响应，失败或响应不一致时用新连接重试一次
+	if conn := b.getIdle(region); conn != nil {
+		body, resp, err := exchangeRaw(ctx, client, conn, msg)
+		if err == nil && replyMatches(msg, resp) {
+			b.putIdle(region, conn)
+			return body, nil
+		}
+		conn.Close()
+	}
+
+	conn, err := client.DialContext(ctx, server.addr)
+	if err != nil {
+		return nil, err
+	}
+	body, resp, err := exchangeRaw(ctx, client, conn, msg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// 不一致的响应交给调用方记录，连接上的报文已错位，不再复用
+	if replyMatches(msg, resp) {
+		b.putIdle(region, conn)
+	} else {
+		conn.Close()
+	}
+	return body, nil
+}
+
+// getIdle 取出一个空闲连接
+func (b *DoTBackend) getIdle(region string) *dns.Conn {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	conns := b.idle[region]
+	if len(conns) == 0 {
+		return nil
+	}
+	conn := conns[len(conns)-1]
+	b.idle[region] = conns[:len(conns)-1]
+	return conn
+}
+
+// putIdle 归还连接，超过上限时直接关闭
+func (b *DoTBackend) putIdle(region string, conn *dns.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.idle[region]) >= maxIdleConns {
+		conn.Close()
+		return
+	}
+	b.idle[region] = append(b.idle[region], conn)
+}
+
+// Close 关闭所有空闲连接
+func (b *DoTBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for region, conns := range b.idle {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		delete(b.idle, region)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// countingListener 统计接受的连接数
+type countingListener struct {
+	net.Listener
+	accepted atomic.Int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+// startDoTServer 启动本地DoT服务器，对所有查询返回一条A记录
+func startDoTServer(t *testing.T, cfg *tls.Config) (string, *countingListener) {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counting := &countingListener{Listener: ln}
+	srv := &dns.Server{
+		Listener: counting,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Compress = true
+			rr, _ := dns.NewRR(r.Question[0].Name + " 60 IN A 192.0.2.1")
+			m.Answer = append(m.Answer, rr)
+			w.WriteMsg(m)
+		}),
+	}
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+	return ln.Addr().String(), counting
+}
+
+func exchangeA(t *testing.T, b Backend, region string) ([]byte, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	return b.Exchange(ctx, region, m)
+}
+
+func TestDoTSNIAndPins(t *testing.T) {
+	cert, pin := testCert(t)
+	var sni sniRecorder
+	addr, _ := startDoTServer(t, sni.serverConfig(cert))
+
+	tests := []struct {
+		name    string
+		server  string
+		opts    TLSOptions
+		wantSNI string
+		wantErr bool
+	}{
+		{"default sni", addr, TLSOptions{ServerName: "dns.test", Pins: []string{pin}}, "dns.test", false},
+		{"per-server sni", addr + "#other.test", TLSOptions{ServerName: "dns.test", Pins: []string{pin}}, "other.test", false},
+		{"wrong pin", addr + "#dns.test", TLSOptions{Pins: []string{wrongPin}}, "dns.test", true},
+		{"unpinned self-signed", addr + "#dns.test", TLSOptions{}, "dns.test", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewDoTBackend([]string{tt.server}, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+
+			body, err := exchangeA(t, b, b.Regions()[0])
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected TLS verification error")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp := new(dns.Msg)
+				if err := resp.Unpack(body); err != nil || len(resp.Answer) != 1 {
+					t.Fatalf("unexpected response: %v %v", resp, err)
+				}
+			}
+			if got := sni.last(); got != tt.wantSNI {
+				t.Errorf("SNI = %q, want %q", got, tt.wantSNI)
+			}
+		})
+	}
+}
+
+func TestDoTReusesIdleConnection(t *testing.T) {
+	cert, pin := testCert(t)
+	var sni sniRecorder
+	addr, ln := startDoTServer(t, sni.serverConfig(cert))
+
+	b, err := NewDoTBackend([]string{addr + "#dns.test"}, TLSOptions{Pins: []string{pin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	for i := 0; i < 3; i++ {
+		body, err := exchangeA(t, b, b.Regions()[0])
+		if err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+		// 返回服务器发送的原始报文，保留名称压缩
+		resp := new(dns.Msg)
+		if err := resp.Unpack(body); err != nil {
+			t.Fatal(err)
+		}
+		resp.Compress = true
+		if packed, _ := resp.Pack(); len(packed) != len(body) {
+			t.Errorf("got %d bytes, want the compressed %d bytes", len(body), len(packed))
+		}
+	}
+	if got := ln.accepted.Load(); got != 1 {
+		t.Errorf("accepted %d connections, want 1", got)
+	}
+}
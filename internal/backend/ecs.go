@@ -0,0 +1,150 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ecsTarget ECS虚拟区域对应的国家子网和实际查询区域
+type ecsTarget struct {
+	subnet      *net.IPNet
+	innerRegion string
+}
+
+// ECSBackend EDNS Client Subnet 地理模拟后端，为每个国家/地区附加对应子网的ECS选项，
+// 再通过内部后端发送到支持ECS的解析器
+type ECSBackend struct {
+	inner   Backend
+	regions []string
+	targets map[string]ecsTarget
+}
+
+// NewECSBackend 创建ECS后端，countries为空或包含all时使用表中的全部国家
+func NewECSBackend(inner Backend, countries []string, table map[string]string) (*ECSBackend, error) {
+	if len(countries) == 0 || (len(countries) == 1 && strings.EqualFold(countries[0], "all")) {
+		countries = make([]string, 0, len(table))
+		for country := range table {
+			countries = append(countries, country)
+		}
+		sort.Strings(countries)
+	}
+
+	innerRegions := inner.Regions()
+	b := &ECSBackend{
+		inner:   inner,
+		targets: make(map[string]ecsTarget),
+	}
+	for _, country := range countries {
+		country = strings.ToUpper(country)
+		cidr, ok := table[country]
+		if !ok {
+			return nil, fmt.Errorf("no ECS subnet for country: %s", country)
+		}
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ECS subnet for %s: %v", country, err)
+		}
+
+		// 内部后端只有一个区域时直接以国家代码作为区域名
+		for _, innerRegion := range innerRegions {
+			region := country
+			if len(innerRegions) > 1 {
+				region = country + "@" + innerRegion
+			}
+			b.regions = append(b.regions, region)
+			b.targets[region] = ecsTarget{subnet: subnet, innerRegion: innerRegion}
+		}
+	}
+	return b, nil
+}
+
+func (b *ECSBackend) Name() string {
+	return fmt.Sprintf("ecs via %s", b.inner.Name())
+}
+
+func (b *ECSBackend) Regions() []string {
+	return b.regions
+}
+
+func (b *ECSBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	target, ok := b.targets[region]
+	if !ok {
+		return nil, fmt.Errorf("unknown region: %s", region)
+	}
+	setClientSubnet(msg, target.subnet)
+	return b.inner.Exchange(ctx, target.innerRegion, msg)
+}
+
+// Upstream 虚拟区域对应的内部区域的上游主机
+func (b *ECSBackend) Upstream(region string) string {
+	if target, ok := b.targets[region]; ok {
+		region = target.innerRegion
+	}
+	return upstreamOf(b.inner, region)
+}
+
+// Close 关闭内部后端
+func (b *ECSBackend) Close() error {
+	if closer, ok := b.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// setClientSubnet 为查询设置ECS选项，替换已有的ECS选项
+func setClientSubnet(msg *dns.Msg, subnet *net.IPNet) {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		msg.SetEdns0(dns.DefaultMsgSize, false)
+		opt = msg.IsEdns0()
+	}
+
+	options := opt.Option[:0]
+	for _, o := range opt.Option {
+		if o.Option() != dns.EDNS0SUBNET {
+			options = append(options, o)
+		}
+	}
+
+	ones, _ := subnet.Mask.Size()
+	ecs := &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		SourceNetmask: uint8(ones),
+		SourceScope:   0,
+		Address:       subnet.IP,
+	}
+	if ip4 := subnet.IP.To4(); ip4 != nil {
+		ecs.Family = 1
+		ecs.Address = ip4
+	} else {
+		ecs.Family = 2
+	}
+	opt.Option = append(options, ecs)
+}
+
+// ParseECSTable 解析自定义ECS表，每行为“国家代码 子网”，支持空格或逗号分隔，#开头为注释
+func ParseECSTable(lines []string) (map[string]string, error) {
+	table := make(map[string]string)
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid ECS table line: %s", line)
+		}
+		if _, _, err := net.ParseCIDR(fields[1]); err != nil {
+			return nil, fmt.Errorf("invalid ECS subnet %s: %v", fields[1], err)
+		}
+		table[strings.ToUpper(fields[0])] = fields[1]
+	}
+	return table, nil
+}
@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// captureBackend 记录每次查询的区域和报文
+type captureBackend struct {
+	stubBackend
+	queried []string
+	msgs    []*dns.Msg
+}
+
+func (b *captureBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	b.queried = append(b.queried, region)
+	b.msgs = append(b.msgs, msg.Copy())
+	return b.stubBackend.Exchange(ctx, region, msg)
+}
+
+func TestParseECSTable(t *testing.T) {
+	table, err := ParseECSTable([]string{
+		"# 注释",
+		"br 200.160.0.0/24",
+		"VN,113.160.0.0/24",
+		"XX\t2001:db8::/56",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"BR": "200.160.0.0/24", "VN": "113.160.0.0/24", "XX": "2001:db8::/56"}
+	if len(table) != len(want) {
+		t.Errorf("table = %v, want %v", table, want)
+	}
+	for country, subnet := range want {
+		if table[country] != subnet {
+			t.Errorf("%s = %q, want %q", country, table[country], subnet)
+		}
+	}
+
+	for _, line := range []string{"BR", "BR 200.160.0.0", "BR not-a-subnet"} {
+		if _, err := ParseECSTable([]string{line}); err == nil {
+			t.Errorf("%q accepted", line)
+		}
+	}
+}
+
+func TestECSVirtualRegions(t *testing.T) {
+	table := map[string]string{"BR": "200.160.0.0/24", "XX": "2001:db8::/56"}
+
+	single, err := NewECSBackend(&stubBackend{regions: []string{"dns.google"}}, []string{"br"}, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := single.Regions(); !slices.Equal(got, []string{"BR"}) {
+		t.Errorf("single upstream regions = %v, want [BR]", got)
+	}
+
+	inner := &captureBackend{stubBackend: stubBackend{regions: []string{"a", "b"}}}
+	multi, err := NewECSBackend(inner, []string{"all"}, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"BR@a", "BR@b", "XX@a", "XX@b"}
+	if got := multi.Regions(); !slices.Equal(got, want) {
+		t.Errorf("regions = %v, want %v", got, want)
+	}
+
+	if _, err := NewECSBackend(inner, []string{"ZZ"}, table); err == nil {
+		t.Error("unknown country accepted")
+	}
+
+	for _, region := range []string{"BR@b", "XX@a"} {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		if _, err := multi.Exchange(context.Background(), region, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(inner.queried, []string{"b", "a"}) {
+		t.Errorf("inner regions = %v, want [b a]", inner.queried)
+	}
+	checks := []struct {
+		family  uint16
+		netmask uint8
+		address string
+	}{
+		{1, 24, "200.160.0.0"},
+		{2, 56, "2001:db8::"},
+	}
+	for i, want := range checks {
+		ecs := clientSubnet(inner.msgs[i])
+		if ecs == nil {
+			t.Fatalf("query %d has no ECS option", i)
+		}
+		if ecs.Family != want.family || ecs.SourceNetmask != want.netmask || ecs.Address.String() != want.address {
+			t.Errorf("query %d ECS = %v, want %s/%d", i, ecs, want.address, want.netmask)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/JaveleyQAQ/geodns/internal/types"
+)
+
+// ErrMalformed 响应无法解析为DNS报文
+var ErrMalformed = errors.New("malformed DNS response")
+
+// RecordedError 回放的录制错误，保留录制时的错误类别，使回放结果与线上一致
+type RecordedError struct {
+	Class   string
+	Message string
+}
+
+func (e *RecordedError) Error() string {
+	return e.Message
+}
+
+// ClassifyError 将后端返回的错误归类，返回错误类别和HTTP状态码（非HTTP错误为0）
+func ClassifyError(err error) (string, int) {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return types.ErrorHTTPStatus, statusErr.StatusCode
+	}
+	var recorded *RecordedError
+	if errors.As(err, &recorded) && recorded.Class != "" {
+		return recorded.Class, 0
+	}
+
+	switch {
+	case errors.Is(err, ErrBudgetExhausted):
+		return types.ErrorSkipped, 0
+	case errors.Is(err, ErrMalformed):
+		return types.ErrorMalformed, 0
+	case isTLSError(err):
+		return types.ErrorTLS, 0
+	case isTimeout(err):
+		return types.ErrorTimeout, 0
+	case isConnectError(err):
+		return types.ErrorConnect, 0
+	}
+	return types.ErrorOther, 0
+}
+
+// isTimeout 判断是否为超时错误
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isTLSError 判断是否为TLS握手或证书错误
+func isTLSError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &unknownAuthErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return true
+	}
+	// 部分传输（如QUIC）只返回文本形式的TLS错误
+	msg := err.Error()
+	return strings.Contains(msg, "tls: ") || strings.Contains(msg, "CRYPTO_ERROR")
+}
+
+// isConnectError 判断是否为建立连接阶段的错误，或连接在返回响应前被对端断开或重置
+func isConnectError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return opErr.Op == "dial" || opErr.Op == "proxyconnect"
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "connection refused") || strings.Contains(msg, "connection reset")
+}
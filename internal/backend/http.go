@@ -0,0 +1,85 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NewHTTPClient 创建HTTP类后端使用的客户端，proxies不为空时通过代理池发送请求
+func NewHTTPClient(proxies *ProxyPool) *http.Client {
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+	}
+	if proxies != nil {
+		transport.Proxy = proxies.Proxy
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   20 * time.Second,
+	}
+}
+
+// newRequest 创建指定区域的HTTP请求，区域信息用于选择代理
+func newRequest(ctx context.Context, region, method, url string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(withProxyRegion(ctx, region), method, url, body)
+}
+
+// doRequest 发送HTTP请求并读取响应体，非200状态视为错误
+func doRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	reportProxy(req.Context(), err)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return body, &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	return body, nil
+}
+
+// HTTPStatusError 非200的HTTP响应
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration // Retry-After 头指定的等待时间，未设置时为0
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status: %s", e.Status)
+}
+
+// parseRetryAfter 解析Retry-After头（秒数或HTTP日期）
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,320 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/config"
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+const (
+	maxIterativeHops  = 30 // 单次迭代解析的最大跳数
+	maxIterativeDepth = 5  // 解析无glue的NS地址时的最大递归深度
+	maxCNAMEHops      = 8  // 应答为CNAME时从根开始继续解析目标的最大次数
+)
+
+// defaultIterativeRegion 未指定起始服务器时的区域名，依次尝试内置根服务器
+const defaultIterativeRegion = "root"
+
+// IterativeBackend 从根开始的迭代解析后端（类似 dig +trace），记录每一跳的转介、glue和最终权威应答。
+// 每个起始服务器作为一个区域；转介到的服务器使用与起始服务器相同的端口
+type IterativeBackend struct {
+	regions []string
+	roots   map[string][]string
+	udp     *dns.Client
+	tcp     *dns.Client
+}
+
+// NewIterativeBackend 创建迭代解析后端，servers为空时使用内置根服务器
+func NewIterativeBackend(servers []string) (*IterativeBackend, error) {
+	b := &IterativeBackend{
+		roots: make(map[string][]string),
+		udp:   &dns.Client{Net: "udp", Timeout: 3 * time.Second, UDPSize: dns.DefaultMsgSize},
+		tcp:   &dns.Client{Net: "tcp", Timeout: 5 * time.Second},
+	}
+
+	if len(servers) == 0 {
+		roots := make([]string, 0, len(config.RootServers))
+		for _, root := range config.RootServers {
+			roots = append(roots, net.JoinHostPort(root, "53"))
+		}
+		b.regions = []string{defaultIterativeRegion}
+		b.roots[defaultIterativeRegion] = roots
+		return b, nil
+	}
+
+	for _, server := range servers {
+		addr, err := normalizeServerAddr(server, "53")
+		if err != nil {
+			return nil, err
+		}
+		b.regions = append(b.regions, addr)
+		b.roots[addr] = []string{addr}
+	}
+	return b, nil
+}
+
+func (b *IterativeBackend) Name() string {
+	return config.ModeIterative
+}
+
+func (b *IterativeBackend) Regions() []string {
+	return b.regions
+}
+
+func (b *IterativeBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	roots, ok := b.roots[region]
+	if !ok {
+		return nil, fmt.Errorf("unknown region: %s", region)
+	}
+	if len(msg.Question) == 0 {
+		return nil, fmt.Errorf("query has no question")
+	}
+
+	resp, err := b.resolveChain(ctx, msg, roots, traceFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	resp.Id = msg.Id
+	return resp.Pack()
+}
+
+// resolveChain 迭代解析查询名，应答只有CNAME时从根开始继续解析链末端的名称，
+// 最终应答的应答部分依次包含链上的全部记录。超过跳数上限或出现环路时停止并在查询路径中记录原因
+func (b *IterativeBackend) resolveChain(ctx context.Context, msg *dns.Msg, roots []string, trace *Trace) (*dns.Msg, error) {
+	resp, err := b.resolve(ctx, msg, roots, trace, 0)
+	q := msg.Question[0]
+	if err != nil || q.Qtype == dns.TypeCNAME || q.Qtype == dns.TypeANY {
+		return resp, err
+	}
+
+	for hop := 0; resp.Rcode == dns.RcodeSuccess; hop++ {
+		end, loop := cnameEnd(resp.Answer, q.Name)
+		if loop {
+			trace.add(types.TraceHop{Zone: end, Error: "CNAME loop, stopped following"})
+			break
+		}
+		if strings.EqualFold(end, q.Name) || hasRRType(resp.Answer, end, q.Qtype) {
+			break
+		}
+		if hop >= maxCNAMEHops {
+			trace.add(types.TraceHop{Zone: end, Error: fmt.Sprintf("more than %d CNAME hops, stopped following", maxCNAMEHops)})
+			break
+		}
+
+		next := msg.Copy()
+		next.Question[0].Name = end
+		target, err := b.resolve(ctx, next, roots, trace, 0)
+		if err != nil {
+			return nil, err
+		}
+		target.Question = msg.Question
+		target.Answer = append(resp.Answer, target.Answer...)
+		resp = target
+	}
+	return resp, nil
+}
+
+// cnameEnd 在应答中从name开始沿CNAME前进，返回链末端的名称；出现环路时返回重复的名称和true
+func cnameEnd(answers []dns.RR, name string) (string, bool) {
+	seen := map[string]bool{strings.ToLower(name): true}
+	for {
+		next := ""
+		for _, rr := range answers {
+			if cname, ok := rr.(*dns.CNAME); ok && strings.EqualFold(cname.Hdr.Name, name) {
+				next = cname.Target
+				break
+			}
+		}
+		if next == "" {
+			return name, false
+		}
+		if seen[strings.ToLower(next)] {
+			return next, true
+		}
+		seen[strings.ToLower(next)] = true
+		name = next
+	}
+}
+
+// hasRRType 应答中是否有name上的qtype记录
+func hasRRType(answers []dns.RR, name string, qtype uint16) bool {
+	for _, rr := range answers {
+		if rr.Header().Rrtype == qtype && strings.EqualFold(rr.Header().Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve 从根服务器开始迭代查询，直到得到权威应答、错误或无法继续转介
+func (b *IterativeBackend) resolve(ctx context.Context, msg *dns.Msg, roots []string, trace *Trace, depth int) (*dns.Msg, error) {
+	_, port, _ := net.SplitHostPort(roots[0])
+	servers := roots
+	zone := "."
+	for hop := 0; hop < maxIterativeHops; hop++ {
+		query := msg.Copy()
+		query.Id = dns.Id()
+		query.RecursionDesired = false
+
+		resp, server, err := b.exchangeAny(ctx, query, servers)
+		if err != nil {
+			trace.add(types.TraceHop{Zone: zone, Server: strings.Join(servers, ","), Error: err.Error()})
+			return nil, err
+		}
+
+		step := types.TraceHop{
+			Zone:          zone,
+			Server:        server,
+			Rcode:         dns.RcodeToString[resp.Rcode],
+			Authoritative: resp.Authoritative,
+		}
+		for _, rr := range resp.Answer {
+			step.Answers = append(step.Answers, rr.String())
+		}
+
+		// 权威应答、错误或带答案的响应即为最终结果
+		if resp.Authoritative || resp.Rcode != dns.RcodeSuccess || len(resp.Answer) > 0 {
+			trace.add(step)
+			return resp, nil
+		}
+
+		referralZone, nsNames := referral(resp)
+		if len(nsNames) == 0 || !dns.IsSubDomain(zone, referralZone) || referralZone == zone {
+			// 没有可用的转介（如无数据的非权威响应），返回当前响应
+			trace.add(step)
+			return resp, nil
+		}
+		step.ReferralZone = referralZone
+		step.Referral = nsNames
+
+		glue := glueAddrs(resp, nsNames)
+		for _, g := range glue {
+			step.Glue = append(step.Glue, g.name+" "+g.ip)
+		}
+		trace.add(step)
+
+		next := make([]string, 0, len(glue))
+		for _, g := range glue {
+			next = append(next, net.JoinHostPort(g.ip, port))
+		}
+		if len(next) == 0 {
+			next = b.resolveNSAddrs(ctx, nsNames, roots, depth)
+		}
+		if len(next) == 0 {
+			return nil, fmt.Errorf("no usable nameserver address for %s", referralZone)
+		}
+
+		if config.IsVerbose() {
+			log.Printf("[iterative] %s -> %s (%d servers)", zone, referralZone, len(next))
+		}
+		zone = referralZone
+		servers = next
+	}
+	return nil, fmt.Errorf("too many referrals (> %d)", maxIterativeHops)
+}
+
+// exchangeAny 依次尝试服务器直到有一个应答，UDP截断时改用TCP
+func (b *IterativeBackend) exchangeAny(ctx context.Context, query *dns.Msg, servers []string) (*dns.Msg, string, error) {
+	var lastErr error
+	for _, server := range servers {
+		resp, _, err := b.udp.ExchangeContext(ctx, query, server)
+		if err == nil && resp.Truncated {
+			resp, _, err = b.tcp.ExchangeContext(ctx, query, server)
+		}
+		if err == nil {
+			return resp, server, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, "", lastErr
+}
+
+// resolveNSAddrs 从根开始解析无glue的NS名称的A和AAAA地址（IPv4优先），不记录到查询路径中
+func (b *IterativeBackend) resolveNSAddrs(ctx context.Context, nsNames []string, roots []string, depth int) []string {
+	if depth >= maxIterativeDepth {
+		return nil
+	}
+	_, port, _ := net.SplitHostPort(roots[0])
+
+	var addrs []string
+	for _, name := range nsNames {
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			query := new(dns.Msg)
+			query.SetQuestion(dns.Fqdn(name), qtype)
+			resp, err := b.resolve(ctx, query, roots, nil, depth+1)
+			if err != nil {
+				continue
+			}
+			for _, rr := range resp.Answer {
+				switch v := rr.(type) {
+				case *dns.A:
+					addrs = append(addrs, net.JoinHostPort(v.A.String(), port))
+				case *dns.AAAA:
+					addrs = append(addrs, net.JoinHostPort(v.AAAA.String(), port))
+				}
+			}
+		}
+		if len(addrs) > 0 {
+			break
+		}
+	}
+	return addrs
+}
+
+// referral 从授权段提取被转介的区域和NS名称
+func referral(resp *dns.Msg) (string, []string) {
+	var zone string
+	var names []string
+	for _, rr := range resp.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+		if zone == "" {
+			zone = ns.Hdr.Name
+		}
+		if strings.EqualFold(ns.Hdr.Name, zone) {
+			names = append(names, ns.Ns)
+		}
+	}
+	return zone, names
+}
+
+// glueRecord NS名称对应的glue地址
+type glueRecord struct {
+	name string
+	ip   string
+}
+
+// glueAddrs 从附加段提取NS名称的glue地址，IPv4优先
+func glueAddrs(resp *dns.Msg, nsNames []string) []glueRecord {
+	wanted := make(map[string]bool, len(nsNames))
+	for _, name := range nsNames {
+		wanted[strings.ToLower(name)] = true
+	}
+
+	var v4, v6 []glueRecord
+	for _, rr := range resp.Extra {
+		name := strings.ToLower(rr.Header().Name)
+		if !wanted[name] {
+			continue
+		}
+		switch v := rr.(type) {
+		case *dns.A:
+			v4 = append(v4, glueRecord{name: rr.Header().Name, ip: v.A.String()})
+		case *dns.AAAA:
+			v6 = append(v6, glueRecord{name: rr.Header().Name, ip: v.AAAA.String()})
+		}
+	}
+	return append(v4, v6...)
+}
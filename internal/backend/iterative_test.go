@@ -0,0 +1,385 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// startAuthServers 在同一端口的多个回环地址上启动权威服务器（转介使用与起始服务器相同的端口），返回端口
+func startAuthServers(t *testing.T, handlers map[string]dns.HandlerFunc) string {
+	t.Helper()
+	for attempt := 0; attempt < 10; attempt++ {
+		first, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, port, _ := net.SplitHostPort(first.LocalAddr().String())
+
+		conns := map[string]net.PacketConn{"127.0.0.1": first}
+		ok := true
+		for ip := range handlers {
+			if ip == "127.0.0.1" {
+				continue
+			}
+			conn, err := net.ListenPacket("udp", net.JoinHostPort(ip, port))
+			if err != nil {
+				ok = false
+				break
+			}
+			conns[ip] = conn
+		}
+		if !ok {
+			for _, conn := range conns {
+				conn.Close()
+			}
+			continue
+		}
+
+		for ip, conn := range conns {
+			handler, found := handlers[ip]
+			if !found {
+				conn.Close()
+				continue
+			}
+			srv := &dns.Server{PacketConn: conn, Handler: handler}
+			started := make(chan struct{})
+			srv.NotifyStartedFunc = func() { close(started) }
+			go srv.ActivateAndServe()
+			<-started
+			t.Cleanup(func() { srv.Shutdown() })
+		}
+		return port
+	}
+	t.Skip("no common free port on loopback addresses")
+	return ""
+}
+
+// referTo 返回把zone转介到指定NS的处理函数，glue为空时不附带地址
+func referTo(zone string, ns string, glue string) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Ns = append(m.Ns, mustRR(zone+" 3600 IN NS "+ns))
+		if glue != "" {
+			m.Extra = append(m.Extra, mustRR(ns+" 3600 IN A "+glue))
+		}
+		w.WriteMsg(m)
+	}
+}
+
+// answerA 返回对所有查询给出权威A记录的处理函数
+func answerA(ip string) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Authoritative = true
+		m.Answer = append(m.Answer, mustRR(r.Question[0].Name+" 300 IN A "+ip))
+		w.WriteMsg(m)
+	}
+}
+
+func mustRR(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+// resolveIterative 从127.0.0.1开始迭代解析，返回应答和记录的查询路径
+func resolveIterative(t *testing.T, port, name string) (*dns.Msg, []types.TraceHop, error) {
+	t.Helper()
+	b, err := NewIterativeBackend([]string{net.JoinHostPort("127.0.0.1", port)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx, trace := WithTrace(ctx)
+
+	m := new(dns.Msg)
+	m.SetQuestion(name, dns.TypeA)
+	body, err := b.Exchange(ctx, b.Regions()[0], m)
+	if err != nil {
+		return nil, trace.Hops(), err
+	}
+	resp := new(dns.Msg)
+	if err := resp.Unpack(body); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Id != m.Id {
+		t.Errorf("response id = %d, want %d", resp.Id, m.Id)
+	}
+	return resp, trace.Hops(), nil
+}
+
+func TestIterativeFollowsGlue(t *testing.T) {
+	port := startAuthServers(t, map[string]dns.HandlerFunc{
+		"127.0.0.1": referTo("test.", "ns.test.", "127.0.0.2"),
+		"127.0.0.2": referTo("example.test.", "ns.example.test.", "127.0.0.3"),
+		"127.0.0.3": answerA("192.0.2.1"),
+	})
+
+	resp, hops, err := resolveIterative(t, port, "www.example.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.A).A.String() != "192.0.2.1" {
+		t.Fatalf("answer = %v", resp.Answer)
+	}
+
+	want := []types.TraceHop{
+		{
+			Zone: ".", Server: "127.0.0.1:" + port, Rcode: "NOERROR",
+			ReferralZone: "test.", Referral: []string{"ns.test."}, Glue: []string{"ns.test. 127.0.0.2"},
+		},
+		{
+			Zone: "test.", Server: "127.0.0.2:" + port, Rcode: "NOERROR",
+			ReferralZone: "example.test.", Referral: []string{"ns.example.test."}, Glue: []string{"ns.example.test. 127.0.0.3"},
+		},
+		{
+			Zone: "example.test.", Server: "127.0.0.3:" + port, Rcode: "NOERROR", Authoritative: true,
+			Answers: []string{"www.example.test.\t300\tIN\tA\t192.0.2.1"},
+		},
+	}
+	assertHops(t, hops, want)
+}
+
+func TestIterativeResolvesNSWithoutGlue(t *testing.T) {
+	port := startAuthServers(t, map[string]dns.HandlerFunc{
+		"127.0.0.1": func(w dns.ResponseWriter, r *dns.Msg) {
+			// 根对NS名称本身直接给出权威应答，对其他名称转介且不带glue
+			if r.Question[0].Name == "ns.other.test." {
+				answerA("127.0.0.3")(w, r)
+				return
+			}
+			referTo("example.test.", "ns.other.test.", "")(w, r)
+		},
+		"127.0.0.3": answerA("192.0.2.7"),
+	})
+
+	resp, hops, err := resolveIterative(t, port, "www.example.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.A).A.String() != "192.0.2.7" {
+		t.Fatalf("answer = %v", resp.Answer)
+	}
+
+	// 解析NS地址的查询不记录到查询路径中
+	want := []types.TraceHop{
+		{
+			Zone: ".", Server: "127.0.0.1:" + port, Rcode: "NOERROR",
+			ReferralZone: "example.test.", Referral: []string{"ns.other.test."},
+		},
+		{
+			Zone: "example.test.", Server: "127.0.0.3:" + port, Rcode: "NOERROR", Authoritative: true,
+			Answers: []string{"www.example.test.\t300\tIN\tA\t192.0.2.7"},
+		},
+	}
+	assertHops(t, hops, want)
+}
+
+func TestIterativeRejectsUpwardAndLameReferrals(t *testing.T) {
+	tests := []struct {
+		name     string
+		referral dns.HandlerFunc
+	}{
+		{"upward", referTo(".", "ns.root.", "127.0.0.1")},
+		{"same zone", referTo("test.", "ns2.test.", "127.0.0.1")},
+		{"sibling", referTo("other.", "ns.other.", "127.0.0.1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rootQueries atomic.Int32
+			port := startAuthServers(t, map[string]dns.HandlerFunc{
+				"127.0.0.1": func(w dns.ResponseWriter, r *dns.Msg) {
+					rootQueries.Add(1)
+					referTo("test.", "ns.test.", "127.0.0.2")(w, r)
+				},
+				"127.0.0.2": tt.referral,
+			})
+
+			resp, hops, err := resolveIterative(t, port, "www.example.test.")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Answer) != 0 {
+				t.Errorf("answer = %v, want none", resp.Answer)
+			}
+			if n := rootQueries.Load(); n != 1 {
+				t.Errorf("root queried %d times, want 1", n)
+			}
+			if len(hops) != 2 {
+				t.Fatalf("got %d hops, want 2: %+v", len(hops), hops)
+			}
+			if last := hops[1]; last.Zone != "test." || last.ReferralZone != "" || len(last.Referral) != 0 {
+				t.Errorf("last hop = %+v, want no referral from test.", last)
+			}
+		})
+	}
+}
+
+func TestIterativeHopLimit(t *testing.T) {
+	// 每次转介只向下一级，名称层级多于跳数上限
+	labels := make([]string, maxIterativeHops+5)
+	for i := range labels {
+		labels[i] = "a"
+	}
+	name := strings.Join(labels, ".") + "."
+
+	var queries atomic.Int32
+	port := startAuthServers(t, map[string]dns.HandlerFunc{
+		"127.0.0.1": func(w dns.ResponseWriter, r *dns.Msg) {
+			n := int(queries.Add(1))
+			zone := strings.Join(labels[len(labels)-n:], ".") + "."
+			referTo(zone, "ns."+zone, "127.0.0.1")(w, r)
+		},
+	})
+
+	_, hops, err := resolveIterative(t, port, name)
+	if err == nil || !strings.Contains(err.Error(), "too many referrals") {
+		t.Fatalf("err = %v, want too many referrals", err)
+	}
+	if n := queries.Load(); n != maxIterativeHops {
+		t.Errorf("sent %d queries, want %d", n, maxIterativeHops)
+	}
+	if len(hops) != maxIterativeHops {
+		t.Errorf("got %d hops, want %d", len(hops), maxIterativeHops)
+	}
+}
+
+func assertHops(t *testing.T, got, want []types.TraceHop) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d hops, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Zone != w.Zone || g.Server != w.Server || g.Rcode != w.Rcode || g.Authoritative != w.Authoritative ||
+			g.ReferralZone != w.ReferralZone || g.Error != w.Error ||
+			strings.Join(g.Referral, ",") != strings.Join(w.Referral, ",") ||
+			strings.Join(g.Glue, ",") != strings.Join(w.Glue, ",") ||
+			strings.Join(g.Answers, ",") != strings.Join(w.Answers, ",") {
+			t.Errorf("hop %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestIterativeResolvesIPv6OnlyNameserver(t *testing.T) {
+	port := startAuthServers(t, map[string]dns.HandlerFunc{
+		"127.0.0.1": func(w dns.ResponseWriter, r *dns.Msg) {
+			// NS名称只有AAAA记录，A查询为NODATA
+			if r.Question[0].Name == "ns.other.test." {
+				m := new(dns.Msg)
+				m.SetReply(r)
+				m.Authoritative = true
+				if r.Question[0].Qtype == dns.TypeAAAA {
+					m.Answer = append(m.Answer, mustRR("ns.other.test. 300 IN AAAA ::1"))
+				}
+				w.WriteMsg(m)
+				return
+			}
+			referTo("example.test.", "ns.other.test.", "")(w, r)
+		},
+		"::1": answerA("192.0.2.8"),
+	})
+
+	resp, _, err := resolveIterative(t, port, "www.example.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.A).A.String() != "192.0.2.8" {
+		t.Fatalf("answer = %v", resp.Answer)
+	}
+}
+
+// answerCNAME 对www.example.test.给出指向target的权威CNAME，对其他名称给出权威A记录
+func answerCNAME(cnames map[string]string) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		target, ok := cnames[r.Question[0].Name]
+		if !ok {
+			answerA("192.0.2.9")(w, r)
+			return
+		}
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Authoritative = true
+		m.Answer = append(m.Answer, mustRR(r.Question[0].Name+" 300 IN CNAME "+target))
+		w.WriteMsg(m)
+	}
+}
+
+func TestIterativeFollowsCNAME(t *testing.T) {
+	port := startAuthServers(t, map[string]dns.HandlerFunc{
+		"127.0.0.1": func(w dns.ResponseWriter, r *dns.Msg) {
+			if strings.HasSuffix(r.Question[0].Name, ".cdn.test.") {
+				referTo("cdn.test.", "ns.cdn.test.", "127.0.0.3")(w, r)
+				return
+			}
+			referTo("example.test.", "ns.example.test.", "127.0.0.2")(w, r)
+		},
+		"127.0.0.2": answerCNAME(map[string]string{"www.example.test.": "edge.cdn.test."}),
+		"127.0.0.3": answerA("192.0.2.9"),
+	})
+
+	resp, hops, err := resolveIterative(t, port, "www.example.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Question[0].Name != "www.example.test." {
+		t.Errorf("question = %v", resp.Question)
+	}
+	if len(resp.Answer) != 2 || resp.Answer[0].Header().Rrtype != dns.TypeCNAME || resp.Answer[1].(*dns.A).A.String() != "192.0.2.9" {
+		t.Fatalf("answer = %v, want the CNAME and the target's A record", resp.Answer)
+	}
+	// 链末端的名称从根重新解析，查询路径包含两段
+	if len(hops) != 4 || hops[2].Zone != "." || hops[3].Zone != "cdn.test." {
+		t.Errorf("hops = %+v", hops)
+	}
+}
+
+func TestIterativeCNAMELimits(t *testing.T) {
+	chain := make(map[string]string)
+	for i := 0; i <= maxCNAMEHops+1; i++ {
+		chain[fmt.Sprintf("c%d.example.test.", i)] = fmt.Sprintf("c%d.example.test.", i+1)
+	}
+	tests := []struct {
+		name   string
+		cnames map[string]string
+		qname  string
+		want   string
+	}{
+		{"loop", map[string]string{"a.example.test.": "b.example.test.", "b.example.test.": "a.example.test."}, "a.example.test.", "CNAME loop"},
+		{"too long", chain, "c0.example.test.", "CNAME hops"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := startAuthServers(t, map[string]dns.HandlerFunc{
+				"127.0.0.1": referTo("example.test.", "ns.example.test.", "127.0.0.2"),
+				"127.0.0.2": answerCNAME(tt.cnames),
+			})
+
+			resp, hops, err := resolveIterative(t, port, tt.qname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, rr := range resp.Answer {
+				if rr.Header().Rrtype != dns.TypeCNAME {
+					t.Errorf("unexpected record %v", rr)
+				}
+			}
+			if last := hops[len(hops)-1]; !strings.Contains(last.Error, tt.want) {
+				t.Errorf("last hop = %+v, want error containing %q", last, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/config"
+	"github.com/miekg/dns"
+)
+
+// PlainBackend 传统UDP/TCP DNS后端，每个域名服务器地址作为一个区域
+type PlainBackend struct {
+	servers []string
+	network string
+	udp     *dns.Client
+	tcp     *dns.Client
+}
+
+// NewPlainBackend 创建UDP/TCP后端，network为udp时遇到截断响应自动改用TCP重试
+func NewPlainBackend(servers []string, network string) (*PlainBackend, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("%s backend requires at least one nameserver", network)
+	}
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("unsupported network: %s", network)
+	}
+
+	addrs := make([]string, 0, len(servers))
+	for _, server := range servers {
+		addr, err := normalizeServerAddr(server, "53")
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+
+	return &PlainBackend{
+		servers: addrs,
+		network: network,
+		udp:     &dns.Client{Net: "udp", Timeout: 5 * time.Second, UDPSize: dns.DefaultMsgSize},
+		tcp:     &dns.Client{Net: "tcp", Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (b *PlainBackend) Name() string {
+	return b.network
+}
+
+func (b *PlainBackend) Regions() []string {
+	return b.servers
+}
+
+func (b *PlainBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	client := b.udp
+	if b.network == "tcp" {
+		client = b.tcp
+	}
+
+	body, resp, err := dialExchange(ctx, client, region, msg)
+	if err == nil && resp.Truncated && client == b.udp {
+		if config.IsVerbose() {
+			log.Printf("[%s] Truncated UDP response, retrying over TCP", region)
+		}
+		body, _, err = dialExchange(ctx, b.tcp, region, msg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// dialExchange 建立新连接发送一次查询
+func dialExchange(ctx context.Context, client *dns.Client, addr string, msg *dns.Msg) ([]byte, *dns.Msg, error) {
+	conn, err := client.DialContext(ctx, addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+	return exchangeRaw(ctx, client, conn, msg)
+}
+
+// exchangeRaw 与 dns.Client.ExchangeWithConnContext 相同，但同时返回服务器发送的原始报文（保留名称压缩），
+// 使记录的响应大小和录制内容与线上一致。
+//
+// 与查询ID或问题不一致的响应不报错而是原样返回，由调用方记录不一致之处：UDP上继续等待一致的响应
+// （跳过无法解析的报文），直到超时仍没有时返回最先收到的不一致响应；TCP/DoT上直接返回
+func exchangeRaw(ctx context.Context, client *dns.Client, conn *dns.Conn, msg *dns.Msg) ([]byte, *dns.Msg, error) {
+	if opt := msg.IsEdns0(); opt != nil && opt.UDPSize() >= dns.MinMsgSize {
+		conn.UDPSize = opt.UDPSize()
+	} else if opt == nil && client.UDPSize >= dns.MinMsgSize {
+		conn.UDPSize = client.UDPSize
+	}
+	deadline := time.Now().Add(client.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if err := conn.WriteMsg(msg); err != nil {
+		return nil, nil, err
+	}
+	_, udp := conn.Conn.(net.PacketConn)
+	var mismatchBody []byte
+	var mismatch *dns.Msg
+	for {
+		body, err := conn.ReadMsgHeader(nil)
+		if udp && errors.Is(err, dns.ErrShortRead) {
+			continue // 不足报文头长度的UDP报文
+		}
+		if err != nil {
+			if mismatch != nil {
+				return mismatchBody, mismatch, nil
+			}
+			return nil, nil, err
+		}
+		resp := new(dns.Msg)
+		if err := resp.Unpack(body); err != nil {
+			if udp {
+				continue // 可能是伪造或损坏的报文，继续等待
+			}
+			return nil, nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		if !udp || replyMatches(msg, resp) {
+			return body, resp, nil
+		}
+		// UDP上不一致的响应可能是之前超时查询的迟到响应或伪造响应，继续等待但保留第一个
+		if mismatch == nil {
+			mismatchBody, mismatch = body, resp
+		}
+	}
+}
+
+// replyMatches 响应的ID和问题（名称不区分大小写）是否与查询一致
+func replyMatches(query, resp *dns.Msg) bool {
+	if resp.Id != query.Id || len(resp.Question) != len(query.Question) {
+		return false
+	}
+	for i, q := range query.Question {
+		got := resp.Question[i]
+		if !strings.EqualFold(got.Name, q.Name) || got.Qtype != q.Qtype || got.Qclass != q.Qclass {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeServerAddr 将服务器地址规范为 host:port 形式，缺省时补全默认端口
+func normalizeServerAddr(server, defaultPort string) (string, error) {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server, nil
+	}
+	if ip := net.ParseIP(server); ip != nil {
+		return net.JoinHostPort(server, defaultPort), nil
+	}
+	if server == "" {
+		return "", fmt.Errorf("empty nameserver address")
+	}
+	return net.JoinHostPort(server, defaultPort), nil
+}
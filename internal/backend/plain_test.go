@@ -0,0 +1,223 @@
+package backend
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startPlainServer 在同一端口启动UDP和TCP服务器，handler按传输协议生成响应
+func startPlainServer(t *testing.T, handler func(network string, r *dns.Msg) *dns.Msg) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Fatal(err)
+	}
+	serve := func(srv *dns.Server, network string) {
+		srv.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			if m := handler(network, r); m != nil {
+				w.WriteMsg(m)
+			}
+		})
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+		go srv.ActivateAndServe()
+		<-started
+		t.Cleanup(func() { srv.Shutdown() })
+	}
+	serve(&dns.Server{PacketConn: pc}, "udp")
+	serve(&dns.Server{Listener: ln}, "tcp")
+	return pc.LocalAddr().String()
+}
+
+func TestPlainTruncatedFallsBackToTCP(t *testing.T) {
+	var udpQueries, tcpQueries atomic.Int32
+	addr := startPlainServer(t, func(network string, r *dns.Msg) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if network == "udp" {
+			udpQueries.Add(1)
+			m.Truncated = true
+			return m
+		}
+		tcpQueries.Add(1)
+		rr, _ := dns.NewRR(r.Question[0].Name + " 60 IN A 192.0.2.1")
+		m.Answer = append(m.Answer, rr)
+		return m
+	})
+
+	b, err := NewPlainBackend([]string{addr}, "udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := exchangeA(t, b, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := new(dns.Msg)
+	if err := resp.Unpack(body); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Truncated || len(resp.Answer) != 1 {
+		t.Errorf("response tc = %t with %d answers, want the full TCP answer", resp.Truncated, len(resp.Answer))
+	}
+	if udpQueries.Load() != 1 || tcpQueries.Load() != 1 {
+		t.Errorf("udp queries = %d, tcp queries = %d, want 1 each", udpQueries.Load(), tcpQueries.Load())
+	}
+}
+
+func TestPlainTCPDoesNotRetry(t *testing.T) {
+	var tcpQueries atomic.Int32
+	addr := startPlainServer(t, func(network string, r *dns.Msg) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Truncated = true
+		if network == "tcp" {
+			tcpQueries.Add(1)
+		}
+		return m
+	})
+
+	b, err := NewPlainBackend([]string{addr}, "tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exchangeA(t, b, addr); err != nil {
+		t.Fatal(err)
+	}
+	if n := tcpQueries.Load(); n != 1 {
+		t.Errorf("tcp queries = %d, want 1", n)
+	}
+}
+
+// startRawUDPServer 启动UDP服务器，对每个查询依次发送replies返回的所有报文
+func startRawUDPServer(t *testing.T, replies func(query *dns.Msg) [][]byte) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, dns.MaxMsgSize)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := new(dns.Msg)
+			if query.Unpack(buf[:n]) != nil {
+				continue
+			}
+			for _, packet := range replies(query) {
+				pc.WriteTo(packet, addr)
+			}
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// packReply 构建对query的应答，edit可修改ID或问题
+func packReply(t *testing.T, query *dns.Msg, edit func(m *dns.Msg)) []byte {
+	m := new(dns.Msg)
+	m.SetReply(query)
+	rr, _ := dns.NewRR(query.Question[0].Name + " 60 IN A 192.0.2.1")
+	m.Answer = append(m.Answer, rr)
+	if edit != nil {
+		edit(m)
+	}
+	wire, err := m.Pack()
+	if err != nil {
+		t.Error(err)
+	}
+	return wire
+}
+
+func wrongID(m *dns.Msg)       { m.Id++ }
+func wrongQuestion(m *dns.Msg) { m.Question[0].Name = "attacker.example." }
+
+func TestPlainUDPWaitsForMatchingReply(t *testing.T) {
+	addr := startRawUDPServer(t, func(query *dns.Msg) [][]byte {
+		return [][]byte{
+			[]byte("garbage"),
+			packReply(t, query, nil)[:14],
+			packReply(t, query, wrongID),
+			packReply(t, query, wrongQuestion),
+			packReply(t, query, nil),
+		}
+	})
+	b, err := NewPlainBackend([]string{addr}, "udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	body, err := b.Exchange(ctx, addr, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := new(dns.Msg)
+	if err := resp.Unpack(body); err != nil {
+		t.Fatal(err)
+	}
+	if !replyMatches(m, resp) {
+		t.Errorf("got reply id %d question %v, want the matching reply", resp.Id, resp.Question)
+	}
+}
+
+func TestPlainReturnsMismatchedReply(t *testing.T) {
+	edits := map[string]func(*dns.Msg){"wrong id": wrongID, "wrong question": wrongQuestion}
+	for name, edit := range edits {
+		t.Run("udp/"+name, func(t *testing.T) {
+			addr := startRawUDPServer(t, func(query *dns.Msg) [][]byte {
+				return [][]byte{packReply(t, query, edit)}
+			})
+			assertMismatch(t, addr, "udp")
+		})
+		t.Run("tcp/"+name, func(t *testing.T) {
+			addr := startPlainServer(t, func(_ string, r *dns.Msg) *dns.Msg {
+				m := new(dns.Msg)
+				m.SetReply(r)
+				edit(m)
+				return m
+			})
+			assertMismatch(t, addr, "tcp")
+		})
+	}
+}
+
+// assertMismatch 只收到不一致的响应时，查询在超时后返回该响应而不是错误，交给调用方记录
+func assertMismatch(t *testing.T, addr, network string) {
+	t.Helper()
+	b, err := NewPlainBackend([]string{addr}, network)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	body, err := b.Exchange(ctx, addr, m)
+	if err != nil {
+		t.Fatalf("err = %v, want the mismatched reply", err)
+	}
+	resp := new(dns.Msg)
+	if err := resp.Unpack(body); err != nil {
+		t.Fatal(err)
+	}
+	if replyMatches(m, resp) {
+		t.Errorf("reply id %d question %v matches the query", resp.Id, resp.Question)
+	}
+}
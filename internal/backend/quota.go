@@ -0,0 +1,145 @@
+package backend
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Quota 按上游统计的每日请求计数，持久化到本地文件，跨多次运行累计
+type Quota struct {
+	mu     sync.Mutex
+	path   string
+	limit  int
+	day    string
+	counts map[string]int // 当天累计计数（加载时的文件计数加本次运行的请求）
+	taken  map[string]int // 本次运行各上游的请求数，保存时合并到文件中的最新计数
+}
+
+// quotaFile 配额文件格式：日期（UTC）到各上游请求数的映射
+type quotaFile map[string]map[string]int
+
+// DefaultQuotaPath 默认的配额文件路径
+func DefaultQuotaPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".geodns-quota.json"
+	}
+	return filepath.Join(home, ".geodns", "quota.json")
+}
+
+// LoadQuota 加载配额文件，limit为每个上游每天的请求上限，文件不存在时从0开始计数
+func LoadQuota(path string, limit int) (*Quota, error) {
+	q := &Quota{
+		path:   path,
+		limit:  limit,
+		day:    time.Now().UTC().Format("2006-01-02"),
+		counts: make(map[string]int),
+		taken:  make(map[string]int),
+	}
+
+	file, err := readQuotaFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if counts, ok := file[q.day]; ok {
+		q.counts = counts
+	}
+	return q, nil
+}
+
+// readQuotaFile 读取配额文件，文件不存在时返回空记录
+func readQuotaFile(path string) (quotaFile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return quotaFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file quotaFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Take 为上游占用一次请求配额，超出上限时返回false
+func (q *Quota) Take(upstream string) bool {
+	if q == nil {
+		return true
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.limit > 0 && q.counts[upstream] >= q.limit {
+		return false
+	}
+	q.counts[upstream]++
+	q.taken[upstream]++
+	return true
+}
+
+// Exhausted 上游当天的配额是否已用完
+func (q *Quota) Exhausted(upstream string) bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.limit > 0 && q.counts[upstream] >= q.limit
+}
+
+// Save 重新读取配额文件，将本次运行的请求数累加到当天的最新计数后写回（同时运行的其他进程的计数不会被覆盖），
+// 只保留当天的记录
+func (q *Quota) Save() error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	file, err := readQuotaFile(q.path)
+	if err != nil {
+		return err
+	}
+	counts := file[q.day]
+	if counts == nil {
+		counts = make(map[string]int)
+	}
+	for upstream, n := range q.taken {
+		counts[upstream] += n
+	}
+
+	data, err := json.MarshalIndent(quotaFile{q.day: counts}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o755); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免其他进程读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), filepath.Base(q.path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), q.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	// 已写入的请求数不再重复累加
+	q.counts = counts
+	q.taken = make(map[string]int)
+	return nil
+}
@@ -0,0 +1,178 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/config"
+	"github.com/miekg/dns"
+)
+
+// stubBackend 对所有区域返回空应答
+type stubBackend struct {
+	regions []string
+}
+
+func (b *stubBackend) Name() string      { return "stub" }
+func (b *stubBackend) Regions() []string { return b.regions }
+
+func (b *stubBackend) Exchange(_ context.Context, _ string, msg *dns.Msg) ([]byte, error) {
+	resp := new(dns.Msg)
+	resp.SetReply(msg)
+	return resp.Pack()
+}
+
+func TestQuotaExhaustedPerUpstream(t *testing.T) {
+	quota, err := LoadQuota(filepath.Join(t.TempDir(), "quota.json"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewRateLimitedBackend(&stubBackend{regions: []string{"1.1.1.1:53", "8.8.8.8:53"}}, RateLimits{}, quota)
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	if _, err := b.Exchange(context.Background(), "1.1.1.1:53", m); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Exchange(context.Background(), "1.1.1.1:53", m); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("second query err = %v, want ErrBudgetExhausted", err)
+	}
+	if !b.Exhausted("1.1.1.1:53") {
+		t.Error("1.1.1.1 not reported as exhausted")
+	}
+	if b.Exhausted("8.8.8.8:53") {
+		t.Error("8.8.8.8 reported as exhausted before any query")
+	}
+	if _, err := b.Exchange(context.Background(), "8.8.8.8:53", m); err != nil {
+		t.Fatalf("other upstream blocked: %v", err)
+	}
+}
+
+func TestQuotaSaveMergesConcurrentRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	day := time.Now().UTC().Format("2006-01-02")
+	writeQuota(t, path, quotaFile{day: {"a": 5}, "2000-01-01": {"a": 100}})
+
+	first, err := LoadQuota(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := LoadQuota(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Take("a")
+	first.Take("b")
+	second.Take("a")
+	second.Take("a")
+
+	if err := first.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Save(); err != nil {
+		t.Fatal(err)
+	}
+	// 再次保存不会重复累加
+	if err := second.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file quotaFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if len(file) != 1 || file[day]["a"] != 8 || file[day]["b"] != 1 {
+		t.Errorf("quota file = %v, want only %s with a=8 b=1", file, day)
+	}
+}
+
+func writeQuota(t *testing.T, path string, file quotaFile) {
+	t.Helper()
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTargetZone(t *testing.T) {
+	tests := map[string]string{
+		"www.example.com.":   "example.com.",
+		"a.b.example.co.uk.": "example.co.uk.",
+		"foo.github.io.":     "foo.github.io.",
+		"Example.COM":        "example.com.",
+		"co.uk.":             "co.uk.",
+		"com.":               "com.",
+	}
+	for name, want := range tests {
+		if got := targetZone(name); got != want {
+			t.Errorf("targetZone(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestQuotaNotTakenWhenWaitCancelled(t *testing.T) {
+	quota, err := LoadQuota(filepath.Join(t.TempDir(), "quota.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewRateLimitedBackend(&stubBackend{regions: []string{"1.1.1.1:53"}}, RateLimits{Global: 1}, quota)
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	if _, err := b.Exchange(context.Background(), "1.1.1.1:53", m); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.Exchange(ctx, "1.1.1.1:53", m); err == nil {
+		t.Fatal("cancelled query was sent")
+	}
+	// 不限预算时也要计数，被取消的查询不计入
+	if n := quota.counts["1.1.1.1"]; n != 1 {
+		t.Errorf("count = %d, want 1", n)
+	}
+}
+
+func TestUpstreamOfWrappedBackends(t *testing.T) {
+	provider := &config.DNSProvider{BaseURL: "https://surf.example:8443" + config.VercelPath, Regions: []string{"hnd1", "sin1"}}
+	table := map[string]string{"BR": "200.160.0.0/24"}
+	surf, err := NewECSBackend(NewVercelBackend(provider, "google", http.DefaultClient), []string{"BR"}, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := NewECSBackend(&stubBackend{regions: []string{"1.1.1.1:53", "8.8.8.8:53"}}, []string{"BR"}, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		backend Backend
+		region  string
+		want    string
+	}{
+		{NewVercelBackend(provider, "google", http.DefaultClient), "hnd1", "surf.example"},
+		{NewCloudflareBackend(config.CloudflareProvider, http.DefaultClient), "ams", "cloudflare.dns.surf"},
+		{surf, "BR@sin1", "surf.example"},
+		{plain, "BR@8.8.8.8:53", "8.8.8.8"},
+		{&stubBackend{}, "https://dns.google/dns-query", "dns.google"},
+		{&stubBackend{}, "1.1.1.1:853#one.one.one.one", "1.1.1.1"},
+	}
+	for _, tt := range tests {
+		if got := upstreamOf(tt.backend, tt.region); got != tt.want {
+			t.Errorf("upstreamOf(%s, %q) = %q, want %q", tt.backend.Name(), tt.region, got, tt.want)
+		}
+	}
+}
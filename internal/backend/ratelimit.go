@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+	"golang.org/x/time/rate"
+)
+
+// ErrBudgetExhausted 当日请求预算已用完
+var ErrBudgetExhausted = errors.New("daily request budget exhausted")
+
+// RateLimits 各维度的QPS限制，0为不限制
+type RateLimits struct {
+	Global   float64 // 全局QPS上限
+	Upstream float64 // 每个上游服务的QPS
+	Region   float64 // 每个区域的QPS
+	Zone     float64 // 每个目标区域（被查询域名的父域）的QPS
+}
+
+// RateLimitedBackend 限速后端：按全局、上游、区域和目标域名的令牌桶限速，并累计每日请求配额
+type RateLimitedBackend struct {
+	inner  Backend
+	limits RateLimits
+	quota  *Quota
+	global *rate.Limiter
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewRateLimitedBackend 创建限速后端，quota为nil时不限制每日请求数
+func NewRateLimitedBackend(inner Backend, limits RateLimits, quota *Quota) *RateLimitedBackend {
+	return &RateLimitedBackend{
+		inner:    inner,
+		limits:   limits,
+		quota:    quota,
+		global:   newLimiter(limits.Global),
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+func (b *RateLimitedBackend) Name() string {
+	return b.inner.Name()
+}
+
+func (b *RateLimitedBackend) Regions() []string {
+	return b.inner.Regions()
+}
+
+func (b *RateLimitedBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	upstream := upstreamOf(b.inner, region)
+	if b.quota.Exhausted(upstream) {
+		return nil, ErrBudgetExhausted
+	}
+
+	limiters := []*rate.Limiter{
+		b.global,
+		b.limiter("upstream:"+upstream, b.limits.Upstream),
+		b.limiter("region:"+region, b.limits.Region),
+	}
+	if len(msg.Question) > 0 {
+		limiters = append(limiters, b.limiter("zone:"+targetZone(msg.Question[0].Name), b.limits.Zone))
+	}
+	for _, limiter := range limiters {
+		if limiter == nil {
+			continue
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	// 等待结束后才计入配额，被取消的查询不消耗预算
+	if !b.quota.Take(upstream) {
+		return nil, ErrBudgetExhausted
+	}
+	if waited, ok := ctx.Value(waitedKey{}).(func()); ok {
+		waited()
+	}
+
+	return b.inner.Exchange(ctx, region, msg)
+}
+
+type waitedKey struct{}
+
+// WithLimiterWaited 返回context，限速等待结束、实际发出请求前调用waited，用于把排队时间排除在请求耗时之外
+func WithLimiterWaited(ctx context.Context, waited func()) context.Context {
+	return context.WithValue(ctx, waitedKey{}, waited)
+}
+
+// Exhausted 区域所属上游的当日请求预算是否已用完
+func (b *RateLimitedBackend) Exhausted(region string) bool {
+	return b.quota.Exhausted(upstreamOf(b.inner, region))
+}
+
+// Close 保存配额计数并关闭内部后端
+func (b *RateLimitedBackend) Close() error {
+	err := b.quota.Save()
+	if closer, ok := b.inner.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// limiter 获取或创建指定键的令牌桶，qps为0时返回nil
+func (b *RateLimitedBackend) limiter(key string, qps float64) *rate.Limiter {
+	if qps <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	limiter, ok := b.limiters[key]
+	if !ok {
+		limiter = newLimiter(qps)
+		b.limiters[key] = limiter
+	}
+	return limiter
+}
+
+// upstreamOf 区域对应的上游主机：后端实现Upstreamer时由其报告，URL或地址形式的区域取其主机，其余归为后端本身
+func upstreamOf(b Backend, region string) string {
+	if u, ok := b.(Upstreamer); ok {
+		return u.Upstream(region)
+	}
+	if u, err := url.Parse(region); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	hostPort, _, _ := strings.Cut(region, "#")
+	if host, _, err := net.SplitHostPort(hostPort); err == nil {
+		return host
+	}
+	return b.Name()
+}
+
+// newLimiter 创建令牌桶，突发容量为每秒请求数（至少为1），qps为0时返回nil
+func newLimiter(qps float64) *rate.Limiter {
+	if qps <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(qps), int(math.Max(1, math.Ceil(qps))))
+}
+
+// targetZone 被查询名称所属的可注册域（公共后缀加一级，如 example.co.uk.），名称本身是公共后缀时返回名称本身
+func targetZone(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if zone, err := publicsuffix.EffectiveTLDPlusOne(name); err == nil {
+		return dns.Fqdn(zone)
+	}
+	return dns.Fqdn(name)
+}
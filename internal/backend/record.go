@@ -0,0 +1,262 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// manifestFile 录制目录中记录后端信息的文件名
+const manifestFile = "manifest.json"
+
+// recordManifest 录制时的后端信息，回放时用于恢复后端名称和区域列表
+type recordManifest struct {
+	Backend string    `json:"backend"`
+	Regions []string  `json:"regions"`
+	Created time.Time `json:"created"`
+}
+
+// recordEntry 一次区域查询的录制内容
+type recordEntry struct {
+	Backend    string           `json:"backend"`
+	Region     string           `json:"region"`
+	Name       string           `json:"name"`
+	Qtype      string           `json:"qtype"`
+	Qclass     string           `json:"qclass"`
+	Query      []byte           `json:"query"`
+	Response   []byte           `json:"response,omitempty"`
+	Error      string           `json:"error,omitempty"` // HTTP错误时为状态行
+	ErrorClass string           `json:"error_class,omitempty"`
+	HTTPStatus int              `json:"http_status,omitempty"`
+	RetryAfter float64          `json:"retry_after,omitempty"` // HTTP错误的Retry-After等待时间（秒）
+	Trace      []types.TraceHop `json:"trace,omitempty"`
+	Time       time.Time        `json:"time"`
+}
+
+// RecordingBackend 录制后端：把每个区域的原始响应和请求信息写入目录，供 ReplayBackend 离线回放
+type RecordingBackend struct {
+	inner Backend
+	dir   string
+}
+
+// NewRecordingBackend 创建录制后端并写入录制清单
+func NewRecordingBackend(inner Backend, dir string) (*RecordingBackend, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	manifest := recordManifest{
+		Backend: inner.Name(),
+		Regions: inner.Regions(),
+		Created: time.Now().UTC(),
+	}
+	if err := writeJSON(filepath.Join(dir, manifestFile), manifest); err != nil {
+		return nil, err
+	}
+	return &RecordingBackend{inner: inner, dir: dir}, nil
+}
+
+func (b *RecordingBackend) Name() string {
+	return b.inner.Name()
+}
+
+func (b *RecordingBackend) Regions() []string {
+	return b.inner.Regions()
+}
+
+// Upstream 内部后端的上游主机
+func (b *RecordingBackend) Upstream(region string) string {
+	return upstreamOf(b.inner, region)
+}
+
+func (b *RecordingBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	body, err := b.inner.Exchange(ctx, region, msg)
+
+	entry := recordEntry{
+		Backend:  b.inner.Name(),
+		Region:   region,
+		Response: body,
+		Trace:    traceFromContext(ctx).Hops(),
+		Time:     time.Now().UTC(),
+	}
+	if len(msg.Question) > 0 {
+		q := msg.Question[0]
+		entry.Name = q.Name
+		entry.Qtype = dns.TypeToString[q.Qtype]
+		entry.Qclass = dns.ClassToString[q.Qclass]
+	}
+	entry.Query, _ = msg.Pack()
+	if err != nil {
+		entry.Error = err.Error()
+		entry.ErrorClass, _ = ClassifyError(err)
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) {
+			entry.HTTPStatus = statusErr.StatusCode
+			entry.Error = statusErr.Status
+			entry.RetryAfter = statusErr.RetryAfter.Seconds()
+		}
+	}
+
+	// 录制失败不影响查询本身
+	if writeErr := writeJSON(filepath.Join(b.dir, recordKey(region, msg)+".json"), entry); writeErr != nil {
+		return body, errors.Join(err, fmt.Errorf("record response: %w", writeErr))
+	}
+	return body, err
+}
+
+// Close 关闭内部后端
+func (b *RecordingBackend) Close() error {
+	if closer, ok := b.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// ReplayBackend 回放后端：从录制目录读取响应，不访问网络
+type ReplayBackend struct {
+	name    string
+	regions []string
+	entries map[string]recordEntry
+}
+
+// NewReplayBackend 加载录制目录
+func NewReplayBackend(dir string) (*ReplayBackend, error) {
+	var manifest recordManifest
+	if err := readJSON(filepath.Join(dir, manifestFile), &manifest); err != nil {
+		return nil, fmt.Errorf("read replay manifest: %w", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]recordEntry, len(files))
+	for _, file := range files {
+		if filepath.Base(file) == manifestFile {
+			continue
+		}
+		var entry recordEntry
+		if err := readJSON(file, &entry); err != nil {
+			return nil, fmt.Errorf("read %s: %w", file, err)
+		}
+		entries[strings.TrimSuffix(filepath.Base(file), ".json")] = entry
+	}
+
+	return &ReplayBackend{
+		name:    manifest.Backend,
+		regions: manifest.Regions,
+		entries: entries,
+	}, nil
+}
+
+func (b *ReplayBackend) Name() string {
+	return fmt.Sprintf("replay (%s)", b.name)
+}
+
+func (b *ReplayBackend) Regions() []string {
+	return b.regions
+}
+
+func (b *ReplayBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	entry, ok := b.entries[recordKey(region, msg)]
+	if !ok {
+		return nil, fmt.Errorf("no recorded response for region %s", region)
+	}
+
+	trace := traceFromContext(ctx)
+	for _, hop := range entry.Trace {
+		trace.add(hop)
+	}
+
+	if entry.Error != "" {
+		if entry.HTTPStatus != 0 {
+			return entry.Response, &HTTPStatusError{
+				StatusCode: entry.HTTPStatus,
+				Status:     entry.Error,
+				RetryAfter: time.Duration(entry.RetryAfter * float64(time.Second)),
+			}
+		}
+		return entry.Response, &RecordedError{Class: entry.ErrorClass, Message: entry.Error}
+	}
+
+	// 本次查询的报文ID与录制时不同，替换为当前ID
+	body := append([]byte(nil), entry.Response...)
+	if len(body) >= 2 {
+		binary.BigEndian.PutUint16(body, msg.Id)
+	}
+	restoreQnameCase(body, msg)
+	return body, nil
+}
+
+// restoreQnameCase 录制时与本次查询的0x20大小写不同，仅大小写不同时按本次查询改写问题名
+func restoreQnameCase(body []byte, msg *dns.Msg) {
+	if len(msg.Question) == 0 {
+		return
+	}
+	name := make([]byte, 256)
+	n, err := dns.PackDomainName(msg.Question[0].Name, name, 0, nil, false)
+	if err != nil || len(body) < 12+n {
+		return
+	}
+	// 问题部分紧跟12字节的报文头
+	if bytes.EqualFold(body[12:12+n], name[:n]) {
+		copy(body[12:], name[:n])
+	}
+}
+
+// recordKey 按区域、问题、CD/DO位和EDNS选项生成录制文件名，不同查询参数的响应分别录制。
+// 客户端cookie每次运行随机生成，只按选项类型区分
+func recordKey(region string, msg *dns.Msg) string {
+	parts := []string{region}
+	for _, q := range msg.Question {
+		parts = append(parts, strings.ToLower(q.Name), dns.TypeToString[q.Qtype], dns.ClassToString[q.Qclass])
+	}
+	if msg.CheckingDisabled {
+		parts = append(parts, "cd")
+	}
+	if opt := msg.IsEdns0(); opt != nil {
+		parts = append(parts, fmt.Sprintf("edns=%d/%d", opt.Version(), opt.UDPSize()))
+		if opt.Do() {
+			parts = append(parts, "do")
+		}
+		for _, o := range opt.Option {
+			if o.Option() == dns.EDNS0COOKIE {
+				parts = append(parts, "cookie")
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("opt%d=%s", o.Option(), o.String()))
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// writeJSON 以缩进格式写入JSON文件
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// readJSON 读取JSON文件
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
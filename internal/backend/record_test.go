@@ -0,0 +1,124 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// errorBackend 按区域返回指定错误，没有指定错误的区域返回空应答
+type errorBackend struct {
+	stubBackend
+	errs map[string]error
+}
+
+func (b *errorBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	if err := b.errs[region]; err != nil {
+		return nil, err
+	}
+	return b.stubBackend.Exchange(ctx, region, msg)
+}
+
+func TestRecordKeyIncludesQueryOptions(t *testing.T) {
+	base := func() *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		return m
+	}
+	withEDNS := func(do bool, opts ...dns.EDNS0) *dns.Msg {
+		m := base()
+		m.SetEdns0(1232, do)
+		m.IsEdns0().Option = opts
+		return m
+	}
+	cd := base()
+	cd.CheckingDisabled = true
+	ecs := func(ip string) dns.EDNS0 {
+		return &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP(ip).To4()}
+	}
+
+	keys := map[string]string{
+		"plain":  recordKey("r", base()),
+		"cd":     recordKey("r", cd),
+		"edns":   recordKey("r", withEDNS(false)),
+		"do":     recordKey("r", withEDNS(true)),
+		"ecs BR": recordKey("r", withEDNS(false, ecs("200.160.0.0"))),
+		"ecs VN": recordKey("r", withEDNS(false, ecs("113.160.0.0"))),
+		"nsid":   recordKey("r", withEDNS(false, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})),
+	}
+	seen := make(map[string]string)
+	for name, key := range keys {
+		if other, ok := seen[key]; ok {
+			t.Errorf("%s and %s share a record key", name, other)
+		}
+		seen[key] = name
+	}
+
+	// 客户端cookie每次运行不同，不影响录制文件名
+	cookieA := recordKey("r", withEDNS(false, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "0011223344556677"}))
+	cookieB := recordKey("r", withEDNS(false, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: "8899aabbccddeeff"}))
+	if cookieA != cookieB {
+		t.Error("client cookie value changed the record key")
+	}
+}
+
+func TestReplayRestoresErrorClass(t *testing.T) {
+	dir := t.TempDir()
+	inner := &errorBackend{
+		stubBackend: stubBackend{regions: []string{"ok", "timeout", "refused", "http"}},
+		errs: map[string]error{
+			"timeout": fmt.Errorf("read udp: %w", os.ErrDeadlineExceeded),
+			"refused": &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			"http":    &HTTPStatusError{StatusCode: 429, Status: "429 Too Many Requests", RetryAfter: 1500 * time.Millisecond},
+		},
+	}
+	recorder, err := NewRecordingBackend(inner, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := func(b Backend, region string) ([]byte, error) {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		return b.Exchange(context.Background(), region, m)
+	}
+	for _, region := range inner.regions {
+		query(recorder, region)
+	}
+
+	replay, err := NewReplayBackend(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"timeout": types.ErrorTimeout,
+		"refused": types.ErrorConnect,
+		"http":    types.ErrorHTTPStatus,
+	}
+	for region, class := range want {
+		_, err := query(replay, region)
+		if err == nil {
+			t.Errorf("%s: replay returned no error", region)
+			continue
+		}
+		if got, _ := ClassifyError(err); got != class {
+			t.Errorf("%s: replayed error class = %s, want %s", region, got, class)
+		}
+	}
+
+	var statusErr *HTTPStatusError
+	if _, err := query(replay, "http"); !errors.As(err, &statusErr) || statusErr.StatusCode != 429 {
+		t.Errorf("http: replayed error = %v, want HTTP 429", err)
+	} else if statusErr.RetryAfter != 1500*time.Millisecond {
+		t.Errorf("http: replayed Retry-After = %v, want 1.5s", statusErr.RetryAfter)
+	}
+	if _, err := query(replay, "ok"); err != nil {
+		t.Errorf("ok: %v", err)
+	}
+}
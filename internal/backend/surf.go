@@ -0,0 +1,109 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/JaveleyQAQ/geodns/internal/config"
+	"github.com/JaveleyQAQ/geodns/internal/query"
+	"github.com/miekg/dns"
+)
+
+// VercelBackend vercel.dns.surf 区域查询后端
+type VercelBackend struct {
+	provider *config.DNSProvider
+	resolver string
+	client   *http.Client
+}
+
+// NewVercelBackend 创建vercel.dns.surf后端
+func NewVercelBackend(provider *config.DNSProvider, resolver string, client *http.Client) *VercelBackend {
+	return &VercelBackend{
+		provider: provider,
+		resolver: resolver,
+		client:   client,
+	}
+}
+
+func (b *VercelBackend) Name() string {
+	return fmt.Sprintf("%s (resolver: %s)", config.ModeVercel, b.resolver)
+}
+
+func (b *VercelBackend) Regions() []string {
+	return b.provider.Regions
+}
+
+// Upstream 所有区域都发往接口地址的主机
+func (b *VercelBackend) Upstream(string) string {
+	return providerHost(b.provider)
+}
+
+func (b *VercelBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	encodedQuery, err := query.Encode(msg)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf(b.provider.BaseURL, region, encodedQuery, b.resolver, region, query.RandFloat())
+	req, err := newRequest(ctx, region, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return doRequest(b.client, req)
+}
+
+// CloudflareBackend cloudflare.dns.surf 区域查询后端
+type CloudflareBackend struct {
+	provider *config.DNSProvider
+	client   *http.Client
+}
+
+// NewCloudflareBackend 创建cloudflare.dns.surf后端
+func NewCloudflareBackend(provider *config.DNSProvider, client *http.Client) *CloudflareBackend {
+	return &CloudflareBackend{
+		provider: provider,
+		client:   client,
+	}
+}
+
+func (b *CloudflareBackend) Name() string {
+	return config.ModeCloudflare
+}
+
+func (b *CloudflareBackend) Regions() []string {
+	return b.provider.Regions
+}
+
+// Upstream 所有区域都发往接口地址的主机
+func (b *CloudflareBackend) Upstream(string) string {
+	return providerHost(b.provider)
+}
+
+func (b *CloudflareBackend) Exchange(ctx context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	encodedQuery, err := query.Encode(msg)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf(b.provider.BaseURL, encodedQuery, region, query.RandFloat())
+	req, err := newRequest(ctx, region, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return doRequest(b.client, req)
+}
+
+// providerHost dns.surf接口地址中的主机名；BaseURL含格式占位符，只解析路径之前的部分
+func providerHost(provider *config.DNSProvider) string {
+	base := provider.BaseURL
+	if scheme, rest, ok := strings.Cut(base, "://"); ok {
+		host, _, _ := strings.Cut(rest, "/")
+		host, _, _ = strings.Cut(host, "?")
+		base = scheme + "://" + host
+	}
+	if u, err := url.Parse(base); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return provider.BaseURL
+}
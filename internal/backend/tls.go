@@ -0,0 +1,110 @@
+package backend
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// TLSOptions 加密传输（DoT/DoQ）的TLS参数
+type TLSOptions struct {
+	ServerName string   // 默认SNI，服务器地址未指定 #name 时使用
+	Pins       []string // 证书公钥固定，base64编码的SPKI SHA-256
+}
+
+// encryptedServer 加密传输的服务器配置
+type encryptedServer struct {
+	addr      string
+	tlsConfig *tls.Config
+}
+
+// parseEncryptedServers 解析 host[:port][#servername] 形式的服务器列表，返回区域名到服务器配置的映射
+func parseEncryptedServers(servers []string, defaultPort string, alpn []string, opts TLSOptions) ([]string, map[string]*encryptedServer, error) {
+	pins, err := decodePins(opts.Pins)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	regions := make([]string, 0, len(servers))
+	configs := make(map[string]*encryptedServer, len(servers))
+	for _, server := range servers {
+		hostPort, serverName, _ := strings.Cut(server, "#")
+		addr, err := normalizeServerAddr(hostPort, defaultPort)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if serverName == "" {
+			serverName = opts.ServerName
+		}
+		if serverName == "" {
+			host, _, _ := net.SplitHostPort(addr)
+			if net.ParseIP(host) == nil {
+				serverName = host
+			}
+		}
+
+		region := addr
+		if serverName != "" {
+			region = addr + "#" + serverName
+		}
+		configs[region] = &encryptedServer{
+			addr:      addr,
+			tlsConfig: newTLSConfig(serverName, alpn, pins),
+		}
+		regions = append(regions, region)
+	}
+	return regions, configs, nil
+}
+
+// newTLSConfig 创建TLS配置，设置公钥固定时只校验固定的公钥，不再校验证书链
+func newTLSConfig(serverName string, alpn []string, pins [][]byte) *tls.Config {
+	cfg := &tls.Config{
+		ServerName: serverName,
+		NextProtos: alpn,
+		MinVersion: tls.VersionTLS12,
+	}
+	if len(pins) == 0 {
+		return cfg
+	}
+
+	cfg.InsecureSkipVerify = true
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		for _, cert := range cs.PeerCertificates {
+			if matchPin(cert, pins) {
+				return nil
+			}
+		}
+		return fmt.Errorf("no certificate matches the configured pins")
+	}
+	return cfg
+}
+
+// decodePins 解码base64编码的SPKI SHA-256公钥固定值
+func decodePins(pins []string) ([][]byte, error) {
+	decoded := make([][]byte, 0, len(pins))
+	for _, pin := range pins {
+		pin = strings.TrimPrefix(pin, "sha256/")
+		b, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid pin: %s (expected base64 SPKI SHA-256)", pin)
+		}
+		decoded = append(decoded, b)
+	}
+	return decoded, nil
+}
+
+// matchPin 检查证书公钥是否与固定值匹配
+func matchPin(cert *x509.Certificate, pins [][]byte) bool {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	for _, pin := range pins {
+		if string(sum[:]) == string(pin) {
+			return true
+		}
+	}
+	return false
+}
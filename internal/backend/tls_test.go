@@ -0,0 +1,110 @@
+package backend
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCert 生成自签名证书，返回证书和对应的base64 SPKI SHA-256固定值
+func testCert(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dns.test"},
+		DNSNames:     []string{"dns.test", "other.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// sniRecorder 记录服务器收到的SNI
+type sniRecorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+// serverConfig 返回记录SNI的服务器TLS配置
+func (r *sniRecorder) serverConfig(cert tls.Certificate, alpn ...string) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   alpn,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.Lock()
+			r.names = append(r.names, hello.ServerName)
+			r.mu.Unlock()
+			return nil, nil
+		},
+	}
+}
+
+func (r *sniRecorder) last() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.names) == 0 {
+		return ""
+	}
+	return r.names[len(r.names)-1]
+}
+
+// wrongPin 一个合法但不匹配任何证书的固定值
+var wrongPin = base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+func TestParseEncryptedServersSNI(t *testing.T) {
+	tests := []struct {
+		server     string
+		defaultSNI string
+		region     string
+		sni        string
+	}{
+		{"127.0.0.1", "", "127.0.0.1:853", ""},
+		{"127.0.0.1#dns.test", "", "127.0.0.1:853#dns.test", "dns.test"},
+		{"127.0.0.1:8853", "dns.test", "127.0.0.1:8853#dns.test", "dns.test"},
+		{"127.0.0.1#other.test", "dns.test", "127.0.0.1:853#other.test", "other.test"},
+		{"dns.google", "", "dns.google:853#dns.google", "dns.google"},
+	}
+	for _, tt := range tests {
+		regions, configs, err := parseEncryptedServers([]string{tt.server}, "853", nil, TLSOptions{ServerName: tt.defaultSNI})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.server, err)
+		}
+		if regions[0] != tt.region {
+			t.Errorf("%s: region = %q, want %q", tt.server, regions[0], tt.region)
+		}
+		if got := configs[regions[0]].tlsConfig.ServerName; got != tt.sni {
+			t.Errorf("%s: SNI = %q, want %q", tt.server, got, tt.sni)
+		}
+	}
+}
+
+func TestDecodePinsRejectsInvalid(t *testing.T) {
+	if _, err := decodePins([]string{"not-base64!"}); err == nil {
+		t.Error("expected error for invalid pin")
+	}
+	if _, err := decodePins([]string{base64.StdEncoding.EncodeToString([]byte("short"))}); err == nil {
+		t.Error("expected error for pin of wrong length")
+	}
+}
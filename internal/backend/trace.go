@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"context"
+	"sync"
+
+	"github.com/JaveleyQAQ/geodns/internal/types"
+)
+
+// Trace 查询过程记录，由支持的后端（如迭代解析）在Exchange中填充
+type Trace struct {
+	mu   sync.Mutex
+	hops []types.TraceHop
+}
+
+type traceKey struct{}
+
+// WithTrace 返回携带查询过程记录的context
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	trace := &Trace{}
+	return context.WithValue(ctx, traceKey{}, trace), trace
+}
+
+// traceFromContext 获取context中的查询过程记录，不存在时返回nil
+func traceFromContext(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey{}).(*Trace)
+	return trace
+}
+
+// add 追加一跳记录，nil记录时忽略
+func (t *Trace) add(hop types.TraceHop) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hops = append(t.hops, hop)
+}
+
+// Hops 返回已记录的查询路径
+func (t *Trace) Hops() []types.TraceHop {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.hops
+}
@@ -0,0 +1,211 @@
+package client
+
+import (
+	"strings"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/query"
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// toAnswer 将资源记录转换为带结构化RDATA的答案，Value为RDATA表示格式（TXT为拼接后的文本）
+func toAnswer(rr dns.RR) types.DNSAnswer {
+	answer := types.DNSAnswer{
+		Name:  rr.Header().Name,
+		Type:  query.TypeName(rr.Header().Rrtype),
+		TTL:   rr.Header().Ttl,
+		Value: query.RData(rr),
+	}
+	if txt, ok := rr.(*dns.TXT); ok {
+		answer.Value = strings.Join(txt.Txt, " ")
+	}
+	answer.RData = rdata(rr)
+	return answer
+}
+
+// rdataByType 按记录类型选择结构化RDATA，RData的结构只由类型决定；未列出的类型按RFC 3597输出
+var rdataByType = map[uint16]func(dns.RR) interface{}{
+	dns.TypeA:     func(rr dns.RR) interface{} { return types.AddressData{Address: rr.(*dns.A).A.String()} },
+	dns.TypeAAAA:  func(rr dns.RR) interface{} { return types.AddressData{Address: rr.(*dns.AAAA).AAAA.String()} },
+	dns.TypeCNAME: func(rr dns.RR) interface{} { return types.TargetData{Target: rr.(*dns.CNAME).Target} },
+	dns.TypeNS:    func(rr dns.RR) interface{} { return types.TargetData{Target: rr.(*dns.NS).Ns} },
+	dns.TypePTR:   func(rr dns.RR) interface{} { return types.TargetData{Target: rr.(*dns.PTR).Ptr} },
+	dns.TypeDNAME: func(rr dns.RR) interface{} { return types.TargetData{Target: rr.(*dns.DNAME).Target} },
+	dns.TypeMX: func(rr dns.RR) interface{} {
+		v := rr.(*dns.MX)
+		return types.MXData{Preference: v.Preference, Exchange: v.Mx}
+	},
+	dns.TypeSRV: func(rr dns.RR) interface{} {
+		v := rr.(*dns.SRV)
+		return types.SRVData{Priority: v.Priority, Weight: v.Weight, Port: v.Port, Target: v.Target}
+	},
+	dns.TypeSOA: func(rr dns.RR) interface{} {
+		v := rr.(*dns.SOA)
+		return types.SOAData{
+			MName:   v.Ns,
+			RName:   v.Mbox,
+			Serial:  v.Serial,
+			Refresh: v.Refresh,
+			Retry:   v.Retry,
+			Expire:  v.Expire,
+			Minimum: v.Minttl,
+		}
+	},
+	dns.TypeTXT: func(rr dns.RR) interface{} { return types.TXTData{Strings: rr.(*dns.TXT).Txt} },
+	dns.TypeSPF: func(rr dns.RR) interface{} { return types.TXTData{Strings: rr.(*dns.SPF).Txt} },
+	dns.TypeCAA: func(rr dns.RR) interface{} {
+		v := rr.(*dns.CAA)
+		return types.CAAData{Flag: v.Flag, Tag: v.Tag, Value: v.Value}
+	},
+	dns.TypeDS:      func(rr dns.RR) interface{} { return dsData(rr.(*dns.DS)) },
+	dns.TypeCDS:     func(rr dns.RR) interface{} { return dsData(&rr.(*dns.CDS).DS) },
+	dns.TypeDNSKEY:  func(rr dns.RR) interface{} { return dnskeyData(rr.(*dns.DNSKEY)) },
+	dns.TypeCDNSKEY: func(rr dns.RR) interface{} { return dnskeyData(&rr.(*dns.CDNSKEY).DNSKEY) },
+	dns.TypeRRSIG: func(rr dns.RR) interface{} {
+		v := rr.(*dns.RRSIG)
+		return types.RRSIGData{
+			TypeCovered: query.TypeName(v.TypeCovered),
+			Algorithm:   v.Algorithm,
+			Labels:      v.Labels,
+			OriginalTTL: v.OrigTtl,
+			Expiration:  rrsigTime(v.Expiration),
+			Inception:   rrsigTime(v.Inception),
+			KeyTag:      v.KeyTag,
+			SignerName:  v.SignerName,
+			Signature:   v.Signature,
+		}
+	},
+	dns.TypeNSEC: func(rr dns.RR) interface{} {
+		v := rr.(*dns.NSEC)
+		return types.NSECData{NextDomain: v.NextDomain, Types: typeNames(v.TypeBitMap)}
+	},
+	dns.TypeNSEC3: func(rr dns.RR) interface{} {
+		v := rr.(*dns.NSEC3)
+		return types.NSEC3Data{
+			HashAlgorithm: v.Hash,
+			Flags:         v.Flags,
+			Iterations:    v.Iterations,
+			Salt:          strings.ToLower(v.Salt),
+			NextDomain:    v.NextDomain,
+			Types:         typeNames(v.TypeBitMap),
+		}
+	},
+	dns.TypeNSEC3PARAM: func(rr dns.RR) interface{} {
+		v := rr.(*dns.NSEC3PARAM)
+		return types.NSEC3PARAMData{HashAlgorithm: v.Hash, Flags: v.Flags, Iterations: v.Iterations, Salt: strings.ToLower(v.Salt)}
+	},
+	dns.TypeTLSA: func(rr dns.RR) interface{} {
+		v := rr.(*dns.TLSA)
+		return types.TLSAData{Usage: v.Usage, Selector: v.Selector, MatchingType: v.MatchingType, Certificate: strings.ToLower(v.Certificate)}
+	},
+	dns.TypeSMIMEA: func(rr dns.RR) interface{} {
+		v := rr.(*dns.SMIMEA)
+		return types.TLSAData{Usage: v.Usage, Selector: v.Selector, MatchingType: v.MatchingType, Certificate: strings.ToLower(v.Certificate)}
+	},
+	dns.TypeSVCB:  func(rr dns.RR) interface{} { return svcbData(rr.(*dns.SVCB)) },
+	dns.TypeHTTPS: func(rr dns.RR) interface{} { return svcbData(&rr.(*dns.HTTPS).SVCB) },
+	dns.TypeNAPTR: func(rr dns.RR) interface{} {
+		v := rr.(*dns.NAPTR)
+		return types.NAPTRData{
+			Order:       v.Order,
+			Preference:  v.Preference,
+			Flags:       v.Flags,
+			Service:     v.Service,
+			Regexp:      v.Regexp,
+			Replacement: v.Replacement,
+		}
+	},
+	dns.TypeSSHFP: func(rr dns.RR) interface{} {
+		v := rr.(*dns.SSHFP)
+		return types.SSHFPData{Algorithm: v.Algorithm, FPType: v.Type, Fingerprint: strings.ToLower(v.FingerPrint)}
+	},
+}
+
+// rdata 按类型转换RDATA；有专门结构的类型以RFC 3597通用格式到达时（如 \# 语法）先解析为具体类型，
+// 保证同一类型总是得到同一结构
+func rdata(rr dns.RR) interface{} {
+	convert, ok := rdataByType[rr.Header().Rrtype]
+	if unknown, generic := rr.(*dns.RFC3597); generic {
+		if !ok {
+			return types.UnknownData{RDLength: len(unknown.Rdata) / 2, Data: strings.ToLower(unknown.Rdata)}
+		}
+		parsed, err := dns.NewRR(unknown.String())
+		if err != nil {
+			return types.UnknownData{RDLength: len(unknown.Rdata) / 2, Data: strings.ToLower(unknown.Rdata)}
+		}
+		rr = parsed
+	}
+	if ok {
+		return convert(rr)
+	}
+
+	generic := new(dns.RFC3597)
+	if err := generic.ToRFC3597(rr); err != nil {
+		return types.UnknownData{}
+	}
+	return types.UnknownData{RDLength: len(generic.Rdata) / 2, Data: generic.Rdata}
+}
+
+// toAnswers 转换报文的一个部分，跳过OPT伪记录
+func toAnswers(rrs []dns.RR) []types.DNSAnswer {
+	var answers []types.DNSAnswer
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeOPT {
+			continue
+		}
+		answers = append(answers, toAnswer(rr))
+	}
+	return answers
+}
+
+// headerFlags 提取响应头部的AA/TC/RA/AD/CD标志
+func headerFlags(msg *dns.Msg) *types.Flags {
+	return &types.Flags{
+		AA: msg.Authoritative,
+		TC: msg.Truncated,
+		RA: msg.RecursionAvailable,
+		AD: msg.AuthenticatedData,
+		CD: msg.CheckingDisabled,
+	}
+}
+
+// dsData DS/CDS记录的RDATA
+func dsData(v *dns.DS) types.DSData {
+	return types.DSData{KeyTag: v.KeyTag, Algorithm: v.Algorithm, DigestType: v.DigestType, Digest: strings.ToLower(v.Digest)}
+}
+
+// dnskeyData DNSKEY/CDNSKEY记录的RDATA
+func dnskeyData(v *dns.DNSKEY) types.DNSKEYData {
+	return types.DNSKEYData{Flags: v.Flags, Protocol: v.Protocol, Algorithm: v.Algorithm, PublicKey: v.PublicKey, KeyTag: v.KeyTag()}
+}
+
+// svcbData SVCB/HTTPS记录的RDATA
+func svcbData(v *dns.SVCB) types.SVCBData {
+	data := types.SVCBData{Priority: v.Priority, Target: v.Target}
+	if len(v.Value) > 0 {
+		data.Params = make(map[string]string, len(v.Value))
+		for _, kv := range v.Value {
+			data.Params[kv.Key().String()] = kv.String()
+		}
+	}
+	return data
+}
+
+// rrsigTime 将RRSIG的时间（RFC 1982序列号算术）转换为RFC 3339格式
+func rrsigTime(t uint32) string {
+	parsed, err := time.Parse("20060102150405", dns.TimeToString(t))
+	if err != nil {
+		return dns.TimeToString(t)
+	}
+	return parsed.UTC().Format(time.RFC3339)
+}
+
+// typeNames NSEC/NSEC3类型位图中的类型名称
+func typeNames(bitmap []uint16) []string {
+	names := make([]string, len(bitmap))
+	for i, t := range bitmap {
+		names[i] = query.TypeName(t)
+	}
+	return names
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// rdataRecords 覆盖各类结构化RDATA和RFC 3597通用格式的记录
+var rdataRecords = []string{
+	"example.com. 300 IN A 192.0.2.1",
+	"example.com. 300 IN AAAA 2001:db8::1",
+	"www.example.com. 300 IN CNAME example.com.",
+	"example.com. 300 IN MX 10 mail.example.com.",
+	"_sip._tcp.example.com. 300 IN SRV 10 60 5060 sip.example.com.",
+	"example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 2026101801 7200 3600 1209600 300",
+	`example.com. 300 IN TXT "v=spf1 -all" "second"`,
+	`example.com. 300 IN CAA 0 issue "letsencrypt.org"`,
+	"example.com. 3600 IN DS 2371 13 2 C988EC423E3880EB8DD8A46FE06CA230EE23F35B578D64D14E2E7A6F7EA1A4D5",
+	"example.com. 3600 IN DNSKEY 257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+KkxLbxILfDLUT0rAK9iUzy1L53eKGQ==",
+	"example.com. 3600 IN RRSIG A 13 2 300 20261101000000 20261018000000 2371 example.com. dGVzdHNpZ25hdHVyZQ==",
+	"example.com. 3600 IN NSEC www.example.com. A NS SOA MX TXT AAAA RRSIG NSEC DNSKEY",
+	"2vptu5timamqttgl4luu9kg21e0aor3s.example.com. 3600 IN NSEC3 1 0 0 - 2VPTU5TIMAMQTTGL4LUU9KG21E0AOR3T A RRSIG",
+	"example.com. 0 IN NSEC3PARAM 1 0 0 AABBCCDD",
+	"_443._tcp.example.com. 300 IN TLSA 3 1 1 0C72AC70B745AC19998811B131D662C9AC69DBDBE7CB23E5B514B56664C5D3D6",
+	`example.com. 300 IN HTTPS 1 . alpn="h2,h3" ipv4hint="192.0.2.1"`,
+	"example.com. 300 IN SVCB 0 svc.example.com.",
+	`example.com. 300 IN NAPTR 100 10 "S" "SIP+D2U" "" _sip._udp.example.com.`,
+	"example.com. 300 IN SSHFP 4 2 123456789ABCDEF67890123456789ABCDEF67890123456789ABCDEF123456789",
+	"example.com. 300 IN LOC 52 22 23.000 N 4 53 32.000 E -2.00m 0.00m 10000m 10m",
+	"example.com. 300 IN HINFO \"PC\" \"Linux\"",
+	`example.com. 300 IN TYPE65280 \# 4 0A0B0C0D`,
+	`example.com. 3600 IN TYPE43 \# 8 0943 0D02 AABBCCDD`,
+}
+
+func TestRDataGolden(t *testing.T) {
+	answers := make([]types.DNSAnswer, 0, len(rdataRecords))
+	for _, s := range rdataRecords {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		answers = append(answers, toAnswer(rr))
+	}
+	got, err := json.MarshalIndent(answers, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	golden := filepath.Join("testdata", "rdata.golden.json")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("rdata JSON differs from %s (run go test -update after checking the change):\n%s", golden, got)
+	}
+}
+
+func TestRDataGenericKnownType(t *testing.T) {
+	// 以 \# 语法到达的DS记录与解析后的DS记录得到同一结构
+	generic := &dns.RFC3597{
+		Hdr:   dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeDS, Class: dns.ClassINET, Ttl: 3600},
+		Rdata: "09430d02aabbccdd",
+	}
+	want := types.DSData{KeyTag: 2371, Algorithm: 13, DigestType: 2, Digest: "aabbccdd"}
+	if got := rdata(generic); got != want {
+		t.Errorf("rdata = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/JaveleyQAQ/geodns/internal/config"
+	"github.com/JaveleyQAQ/geodns/internal/query"
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// maxChainLength CNAME链允许的最大跳数，超过时视为异常并停止
+const maxChainLength = 16
+
+// buildChain 从应答中重建查询名开始的CNAME链，应答不含CNAME时返回nil
+func buildChain(domain string, qtype uint16, answers []types.DNSAnswer) *types.CNAMEChain {
+	targets := make(map[string]string)
+	for _, answer := range answers {
+		if answer.Type == "CNAME" {
+			targets[strings.ToLower(answer.Name)] = answer.Value
+		}
+	}
+
+	name := dns.Fqdn(domain)
+	chain := &types.CNAMEChain{
+		Names: []string{name},
+		Type:  query.TypeName(qtype),
+	}
+	seen := map[string]bool{strings.ToLower(name): true}
+	for {
+		target, ok := targets[strings.ToLower(name)]
+		if !ok {
+			break
+		}
+		if seen[strings.ToLower(target)] {
+			chain.Names = append(chain.Names, target)
+			chain.Loop = true
+			break
+		}
+		if len(chain.Names) > maxChainLength {
+			chain.TooLong = true
+			break
+		}
+		seen[strings.ToLower(target)] = true
+		chain.Names = append(chain.Names, target)
+		name = target
+	}
+	if len(chain.Names) == 1 {
+		return nil
+	}
+
+	if !chain.Loop {
+		for _, answer := range answers {
+			if answer.Type == chain.Type && strings.EqualFold(answer.Name, name) {
+				chain.Final = append(chain.Final, answer.Value)
+			}
+		}
+	}
+	return chain
+}
+
+// followOutcome 继续查询CNAME链末端的结果
+type followOutcome struct {
+	records []dns.RR // 后续查询得到的记录（已去重，不并入原始响应）
+	queries int      // 额外发出的查询次数
+	rcode   string   // 链末端查询的非NOERROR响应码
+	err     string   // 链末端查询失败的错误
+}
+
+// followChain 区域只返回CNAME时继续查询链末端的名称，直到得到查询类型的记录或链终止；
+// 链末端的查询返回错误响应码或失败时停止并记录结果
+func (c *Client) followChain(ctx context.Context, domain, region string, q, msg *dns.Msg) followOutcome {
+	var out followOutcome
+	qtype := q.Question[0].Qtype
+	if qtype == dns.TypeCNAME || qtype == dns.TypeANY {
+		return out
+	}
+
+	seen := make(map[string]bool)
+	for _, rr := range msg.Answer {
+		seen[rrKey(rr)] = true
+	}
+	for out.queries < maxChainLength {
+		chain := buildChain(domain, qtype, toAnswers(append(append([]dns.RR(nil), msg.Answer...), out.records...)))
+		if chain == nil || chain.Loop || chain.TooLong || len(chain.Final) > 0 {
+			break
+		}
+
+		next := q.Copy()
+		next.Id = dns.Id()
+		next.Question[0].Name = chain.Names[len(chain.Names)-1]
+		_, resp, _, err := c.exchange(ctx, region, next)
+		out.queries++
+		if err != nil {
+			out.err = err.Error()
+		} else if resp.Rcode != dns.RcodeSuccess {
+			out.rcode = dns.RcodeToString[resp.Rcode]
+		}
+		if out.err != "" || out.rcode != "" {
+			if config.IsVerbose() {
+				log.Printf("[%s] Following CNAME to %s failed: %s%s", region, next.Question[0].Name, out.rcode, out.err)
+			}
+			break
+		}
+
+		// 应答没有带来查询名上的新记录时链不会再延伸，停止继续查询
+		progressed := false
+		for _, rr := range resp.Answer {
+			key := rrKey(rr)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out.records = append(out.records, rr)
+			if strings.EqualFold(rr.Header().Name, next.Question[0].Name) {
+				progressed = true
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	return out
+}
+
+// rrKey 记录的去重键，忽略TTL和名称大小写
+func rrKey(rr dns.RR) string {
+	rr = dns.Copy(rr)
+	rr.Header().Ttl = 0
+	return strings.ToLower(rr.String())
+}
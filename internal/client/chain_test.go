@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// zoneBackend 按查询名返回预设的应答记录或响应码，并统计查询次数
+type zoneBackend struct {
+	answers map[string][]string
+	rcodes  map[string]int
+	calls   atomic.Int32
+}
+
+func (b *zoneBackend) Name() string      { return "zone" }
+func (b *zoneBackend) Regions() []string { return []string{"r"} }
+
+func (b *zoneBackend) Exchange(_ context.Context, _ string, msg *dns.Msg) ([]byte, error) {
+	b.calls.Add(1)
+	resp := new(dns.Msg)
+	resp.SetRcode(msg, b.rcodes[strings.ToLower(msg.Question[0].Name)])
+	for _, s := range b.answers[strings.ToLower(msg.Question[0].Name)] {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			return nil, err
+		}
+		resp.Answer = append(resp.Answer, rr)
+	}
+	return resp.Pack()
+}
+
+func followRegion(t *testing.T, b *zoneBackend) types.RegionResult {
+	t.Helper()
+	c := NewClient(b, RetryPolicy{}, nil)
+	c.SetFollowCNAME(true)
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.com.", dns.TypeA)
+	ch := make(chan types.RegionResult, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	c.QueryRegion(context.Background(), "www.example.com", "r", m, &wg, ch)
+	return <-ch
+}
+
+func TestFollowChainKeepsAnswersSeparate(t *testing.T) {
+	b := &zoneBackend{answers: map[string][]string{
+		"www.example.com.": {"www.example.com. 60 IN CNAME cdn.example.net."},
+		"cdn.example.net.": {"cdn.example.net. 60 IN CNAME edge.example.net."},
+		"edge.example.net.": {
+			// 部分解析器会重复返回链上已有的记录
+			"cdn.example.net. 30 IN CNAME edge.example.net.",
+			"edge.example.net. 60 IN A 192.0.2.1",
+		},
+	}}
+	res := followRegion(t, b)
+
+	if len(res.Answers) != 1 || res.Answers[0].Type != "CNAME" {
+		t.Errorf("answers = %+v, want only the original CNAME", res.Answers)
+	}
+	if len(res.Followed) != 2 {
+		t.Errorf("followed = %+v, want 2 deduplicated records", res.Followed)
+	}
+	if res.Chain == nil || res.Chain.Followed != 2 || len(res.Chain.Final) != 1 || res.Chain.Final[0] != "192.0.2.1" {
+		t.Errorf("chain = %+v", res.Chain)
+	}
+}
+
+func TestFollowChainStopsWithoutProgress(t *testing.T) {
+	// 链末端的查询只返回已有的CNAME，没有新记录时不再重复查询
+	b := &zoneBackend{answers: map[string][]string{
+		"www.example.com.": {"www.example.com. 60 IN CNAME cdn.example.net."},
+		"cdn.example.net.": {"www.example.com. 60 IN CNAME cdn.example.net."},
+	}}
+	res := followRegion(t, b)
+
+	if n := b.calls.Load(); n != 2 {
+		t.Errorf("sent %d queries, want 2", n)
+	}
+	if len(res.Followed) != 0 {
+		t.Errorf("followed = %+v, want none", res.Followed)
+	}
+}
+
+func TestChainLoop(t *testing.T) {
+	b := &zoneBackend{answers: map[string][]string{
+		"www.example.com.": {"www.example.com. 60 IN CNAME cdn.example.net."},
+		"cdn.example.net.": {"cdn.example.net. 60 IN CNAME www.example.com."},
+	}}
+	res := followRegion(t, b)
+
+	want := []string{"www.example.com.", "cdn.example.net.", "www.example.com."}
+	if res.Chain == nil || !res.Chain.Loop || strings.Join(res.Chain.Names, " ") != strings.Join(want, " ") {
+		t.Fatalf("chain = %+v, want loop %v", res.Chain, want)
+	}
+	if len(res.Chain.Final) != 0 {
+		t.Errorf("final = %v, want none for a loop", res.Chain.Final)
+	}
+	// 发现环路后不再继续查询
+	if n := b.calls.Load(); n != 2 {
+		t.Errorf("sent %d queries, want 2", n)
+	}
+}
+
+// cnameLadder 返回从www.example.com.开始、共hops跳的CNAME记录，链末端有一条A记录
+func cnameLadder(hops int) []types.DNSAnswer {
+	var answers []types.DNSAnswer
+	name := "www.example.com."
+	for i := 0; i < hops; i++ {
+		target := fmt.Sprintf("c%d.example.net.", i)
+		answers = append(answers, types.DNSAnswer{Name: name, Type: "CNAME", Value: target})
+		name = target
+	}
+	return append(answers, types.DNSAnswer{Name: name, Type: "A", Value: "192.0.2.1"})
+}
+
+func TestChainHopLimit(t *testing.T) {
+	chain := buildChain("www.example.com", dns.TypeA, cnameLadder(maxChainLength))
+	if chain == nil || chain.TooLong || len(chain.Names) != maxChainLength+1 || len(chain.Final) != 1 {
+		t.Errorf("%d hops: chain = %+v, want the full chain with its final record", maxChainLength, chain)
+	}
+
+	chain = buildChain("www.example.com", dns.TypeA, cnameLadder(maxChainLength+1))
+	if chain == nil || !chain.TooLong || len(chain.Names) != maxChainLength+1 || len(chain.Final) != 0 {
+		t.Errorf("%d hops: chain = %+v, want a chain cut at %d hops", maxChainLength+1, chain, maxChainLength)
+	}
+
+	// -follow 逐跳查询时同样在16跳处停止
+	answers := map[string][]string{}
+	for _, answer := range cnameLadder(40) {
+		if answer.Type == "CNAME" {
+			answers[answer.Name] = []string{answer.Name + " 60 IN CNAME " + answer.Value}
+		}
+	}
+	b := &zoneBackend{answers: answers}
+	res := followRegion(t, b)
+	if res.Chain == nil || !res.Chain.TooLong {
+		t.Fatalf("chain = %+v, want too long", res.Chain)
+	}
+	if n := b.calls.Load(); n > maxChainLength+1 {
+		t.Errorf("sent %d queries, want at most %d", n, maxChainLength+1)
+	}
+}
+
+func TestFollowChainRecordsTerminalRcode(t *testing.T) {
+	for _, rcode := range []int{dns.RcodeNameError, dns.RcodeServerFailure} {
+		b := &zoneBackend{
+			answers: map[string][]string{"www.example.com.": {"www.example.com. 60 IN CNAME gone.example.net."}},
+			rcodes:  map[string]int{"gone.example.net.": rcode},
+		}
+		res := followRegion(t, b)
+
+		if res.Error != "" {
+			t.Errorf("region error = %q, the original response succeeded", res.Error)
+		}
+		want := dns.RcodeToString[rcode]
+		if res.Chain == nil || res.Chain.Rcode != want || res.Chain.Followed != 1 || len(res.Chain.Final) != 0 {
+			t.Errorf("chain = %+v, want end rcode %s after 1 query", res.Chain, want)
+		}
+	}
+}
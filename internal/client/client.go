@@ -0,0 +1,484 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/JaveleyQAQ/geodns/internal/backend"
+	"github.com/JaveleyQAQ/geodns/internal/config"
+	"github.com/JaveleyQAQ/geodns/internal/dnssec"
+	"github.com/JaveleyQAQ/geodns/internal/geoip"
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// min 返回两个整数中的较小值
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Client 区域查询客户端，通过后端发送查询并解析结果
+type Client struct {
+	backend   backend.Backend
+	retry     RetryPolicy
+	breaker   *CircuitBreaker
+	validator *dnssec.Validator
+	follow    bool      // 区域只返回CNAME时是否继续查询链末端
+	geo       *geoip.DB // A/AAAA应答的地理位置和ASN数据库，为空时不查询
+	budgetHit atomic.Bool
+}
+
+// NewClient 创建新的查询客户端，breaker为nil时不熔断
+func NewClient(b backend.Backend, retry RetryPolicy, breaker *CircuitBreaker) *Client {
+	return &Client{
+		backend: b,
+		retry:   retry,
+		breaker: breaker,
+	}
+}
+
+// SetValidator 启用逐区域的DNSSEC验证，查询会自动设置DO位
+func (c *Client) SetValidator(v *dnssec.Validator) {
+	c.validator = v
+}
+
+// SetFollowCNAME 设置区域只返回CNAME时是否继续查询链末端的名称
+func (c *Client) SetFollowCNAME(follow bool) {
+	c.follow = follow
+}
+
+// SetGeoIP 设置用于补充A/AAAA应答地理位置和ASN信息的MMDB数据库，Close时一并关闭
+func (c *Client) SetGeoIP(db *geoip.DB) {
+	c.geo = db
+}
+
+// Regions 返回后端的区域列表
+func (c *Client) Regions() []string {
+	return c.backend.Regions()
+}
+
+// Close 释放后端持有的连接
+func (c *Client) Close() error {
+	if c.geo != nil {
+		c.geo.Close()
+	}
+	if closer, ok := c.backend.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Exhausted 区域所属上游的每日请求预算是否已用完
+func (c *Client) Exhausted(region string) bool {
+	if limited, ok := c.backend.(interface{ Exhausted(string) bool }); ok {
+		return limited.Exhausted(region)
+	}
+	return false
+}
+
+// BudgetHit 本次运行中是否有查询因预算用完被跳过
+func (c *Client) BudgetHit() bool {
+	return c.budgetHit.Load()
+}
+
+// QueryRegion 查询指定区域的DNS记录
+func (c *Client) QueryRegion(ctx context.Context, domain, region string, query *dns.Msg, wg *sync.WaitGroup, resultChan chan<- types.RegionResult) {
+	defer wg.Done()
+
+	// 预算用完或熔断中的区域直接跳过，但仍返回结果以便统计
+	if c.Exhausted(region) {
+		c.budgetHit.Store(true)
+		resultChan <- errorResult(domain, region, backend.ErrBudgetExhausted, nil)
+		return
+	}
+	if !c.breaker.Allow(region) {
+		if config.IsVerbose() {
+			log.Printf("[%s] Circuit open, skipping region", region)
+		}
+		resultChan <- errorResult(domain, region, errCircuitOpen, nil)
+		return
+	}
+
+	if c.validator != nil {
+		dnssec.PrepareQuery(query)
+	}
+
+	var body []byte
+	var msg *dns.Msg
+	var trace *backend.Trace
+	var timing *types.Timing
+	var err error
+	for attempt := 0; ; attempt++ {
+		attemptCtx, recorder := withTiming(ctx)
+		body, msg, trace, err = c.exchange(attemptCtx, region, query)
+		timing = recorder.finish()
+		if err == nil || attempt >= c.retry.Retries || !retryable(err) {
+			break
+		}
+		delay := c.retry.backoff(attempt, err)
+		if config.IsVerbose() {
+			log.Printf("[%s] Attempt %d failed: %v, retrying in %v", region, attempt+1, err, delay)
+		}
+		if waitErr := wait(ctx, delay); waitErr != nil {
+			break
+		}
+	}
+
+	// 预算用完不是区域故障，不计入熔断
+	if errors.Is(err, backend.ErrBudgetExhausted) {
+		c.budgetHit.Store(true)
+		c.breaker.Release(region)
+	} else if c.breaker.Record(region, err) && config.IsVerbose() {
+		log.Printf("[%s] Circuit opened after consecutive failures", region)
+	}
+	if err != nil {
+		if config.IsVerbose() {
+			log.Printf("[%s] Error fetching response: %v\n", region, err)
+		}
+		result := errorResult(domain, region, err, trace)
+		result.Timing = timing
+		resultChan <- result
+		return
+	}
+
+	var answers []types.DNSAnswer
+	var aRecords, cnameRecords []string
+
+	mismatches := checkResponse(query, msg)
+	if len(mismatches) > 0 && config.IsVerbose() {
+		log.Printf("[%s] Response does not match query: %s", region, strings.Join(mismatches, "; "))
+	}
+
+	ecs := extractECS(msg)
+	if ecs != nil && config.IsVerbose() {
+		log.Printf("[%s] ECS %s, scope /%d", region, ecs.Subnet, ecs.Scope)
+	}
+
+	// 检查DNS响应状态
+	if msg.Rcode != dns.RcodeSuccess {
+		statusText := getDNSStatusText(msg.Rcode)
+		if config.IsVerbose() {
+			log.Printf("[%s] DNS query failed with status: %s (code: %d)", region, statusText, msg.Rcode)
+		}
+
+		// 对于NXDOMAIN等错误状态，仍然返回结果但标记为错误
+		resultChan <- types.RegionResult{
+			Domain:     domain,
+			Region:     region,
+			Answers:    []types.DNSAnswer{},
+			IPs:        []string{},
+			CNAMEs:     []string{},
+			RawBytes:   body,
+			Authority:  toAnswers(msg.Ns),
+			Additional: toAnswers(msg.Extra),
+			Flags:      headerFlags(msg),
+			Size:       len(body),
+			Rcode:      dns.RcodeToString[msg.Rcode],
+			ECS:        ecs,
+			EDNS:       extractEDNS(msg),
+			DNSSEC:     c.validate(ctx, region, query, msg),
+			Trace:      trace.Hops(),
+			Mismatches: mismatches,
+			Timing:     timing,
+			ErrorClass: types.ErrorRcode,
+			Error:      fmt.Sprintf("DNS %s", statusText),
+		}
+		return
+	}
+
+	dnssecInfo := c.validate(ctx, region, query, msg)
+	// CNAME链后续查询的记录单独保存，Answers只包含区域的原始响应
+	var follow followOutcome
+	if c.follow {
+		follow = c.followChain(ctx, domain, region, query, msg)
+	}
+
+	for _, ans := range msg.Answer {
+		if config.IsVerbose() {
+			log.Printf("[%s] Processing record type: %d", region, ans.Header().Rrtype)
+		}
+		answer := toAnswer(ans)
+		answer.Geo = c.lookupGeo(ans)
+		switch ans.(type) {
+		case *dns.A:
+			aRecords = append(aRecords, answer.Value)
+		case *dns.CNAME:
+			cnameRecords = append(cnameRecords, answer.Value)
+		}
+		answers = append(answers, answer)
+	}
+
+	var followedAnswers []types.DNSAnswer
+	for _, rr := range follow.records {
+		answer := toAnswer(rr)
+		answer.Geo = c.lookupGeo(rr)
+		followedAnswers = append(followedAnswers, answer)
+	}
+	chain := buildChain(domain, query.Question[0].Qtype, append(append([]types.DNSAnswer(nil), answers...), followedAnswers...))
+	if chain != nil {
+		chain.Followed = follow.queries
+		chain.Rcode = follow.rcode
+		chain.Error = follow.err
+	}
+
+	resultChan <- types.RegionResult{
+		Domain:     domain,
+		Region:     region,
+		Answers:    answers,
+		IPs:        aRecords,
+		CNAMEs:     cnameRecords,
+		RawBytes:   body,
+		Authority:  toAnswers(msg.Ns),
+		Additional: toAnswers(msg.Extra),
+		Flags:      headerFlags(msg),
+		Size:       len(body),
+		Rcode:      dns.RcodeToString[msg.Rcode],
+		ECS:        ecs,
+		EDNS:       extractEDNS(msg),
+		DNSSEC:     dnssecInfo,
+		Chain:      chain,
+		Followed:   followedAnswers,
+		Trace:      trace.Hops(),
+		Mismatches: mismatches,
+		Timing:     timing,
+	}
+}
+
+// lookupGeo 查询A/AAAA记录地址的地理位置和ASN，未设置数据库或其他类型返回nil
+func (c *Client) lookupGeo(rr dns.RR) *types.GeoInfo {
+	if c.geo == nil {
+		return nil
+	}
+	switch rr := rr.(type) {
+	case *dns.A:
+		return c.geo.Lookup(rr.A)
+	case *dns.AAAA:
+		return c.geo.Lookup(rr.AAAA)
+	}
+	return nil
+}
+
+// errorResult 构建查询失败区域的结果
+func errorResult(domain, region string, err error, trace *backend.Trace) types.RegionResult {
+	class, httpStatus := classifyError(err)
+	return types.RegionResult{
+		Domain:     domain,
+		Region:     region,
+		Answers:    []types.DNSAnswer{},
+		IPs:        []string{},
+		CNAMEs:     []string{},
+		HTTPStatus: httpStatus,
+		Trace:      trace.Hops(),
+		ErrorClass: class,
+		Error:      err.Error(),
+	}
+}
+
+// exchange 通过后端发送一次查询并解析响应，无法解析的响应体视为失败
+func (c *Client) exchange(ctx context.Context, region string, query *dns.Msg) ([]byte, *dns.Msg, *backend.Trace, error) {
+	ctx, trace := backend.WithTrace(ctx)
+	body, err := c.backend.Exchange(ctx, region, query)
+	if err != nil {
+		return nil, nil, trace, err
+	}
+
+	// 添加调试信息（仅在详细模式下显示）
+	if config.IsVerbose() {
+		log.Printf("[%s] Raw response length: %d bytes", region, len(body))
+		if len(body) < 100 {
+			log.Printf("[%s] Raw response (hex): %x", region, body[:min(len(body), 100)])
+		}
+	}
+
+	msg := new(dns.Msg)
+	if err := msg.Unpack(body); err != nil {
+		if config.IsVerbose() {
+			log.Printf("[%s] Response body (first 100 bytes): %x", region, body[:min(len(body), 100)])
+		}
+		return body, nil, trace, fmt.Errorf("%w: %v", errMalformed, err)
+	}
+
+	if config.IsVerbose() {
+		log.Printf("[%s] DNS response unpacked successfully, %d answers", region, len(msg.Answer))
+	}
+	return body, msg, trace, nil
+}
+
+// validate 在本地验证区域响应的DNSSEC签名链，未启用验证时返回nil
+func (c *Client) validate(ctx context.Context, region string, query, msg *dns.Msg) *types.DNSSECInfo {
+	if c.validator == nil {
+		return nil
+	}
+	info := &types.DNSSECInfo{
+		AD: msg.AuthenticatedData,
+		CD: query.CheckingDisabled,
+	}
+
+	// 验证型解析器对bogus数据返回SERVFAIL，设置CD位重新查询以取得数据并说明原因
+	if msg.Rcode == dns.RcodeServerFailure && !query.CheckingDisabled {
+		cdQuery := query.Copy()
+		cdQuery.CheckingDisabled = true
+		_, cdMsg, _, err := c.exchange(ctx, region, cdQuery)
+		if err != nil {
+			info.Status = types.DNSSECIndeterminate
+			info.Reason = fmt.Sprintf("SERVFAIL; retry with CD failed: %v", err)
+			return info
+		}
+		if cdMsg.Rcode == dns.RcodeServerFailure {
+			info.Status = types.DNSSECIndeterminate
+			info.Reason = "SERVFAIL with CD as well, not caused by DNSSEC validation"
+			return info
+		}
+		status, reason := c.validator.Validate(ctx, region, cdMsg)
+		info.Status = status
+		if status == types.DNSSECBogus {
+			info.Reason = "SERVFAIL; with CD: " + reason
+		} else {
+			info.Reason = fmt.Sprintf("SERVFAIL; data with CD is %s", status)
+			if reason != "" {
+				info.Reason += ": " + reason
+			}
+		}
+		return info
+	}
+
+	info.Status, info.Reason = c.validator.Validate(ctx, region, msg)
+	if config.IsVerbose() {
+		log.Printf("[%s] DNSSEC %s (ad=%v) %s", region, info.Status, info.AD, info.Reason)
+	}
+	return info
+}
+
+// checkResponse 检查响应的ID、QR位和问题部分是否与查询一致，问题名按原样比较以发现0x20大小写被改写。
+// 后端可能按传输要求改写查询ID（如DoH/DoQ使用0），因此在交换之后比较
+func checkResponse(query, msg *dns.Msg) []string {
+	var mismatches []string
+	if msg.Id != query.Id {
+		mismatches = append(mismatches, fmt.Sprintf("id %d != sent %d", msg.Id, query.Id))
+	}
+	if !msg.Response {
+		mismatches = append(mismatches, "qr bit not set")
+	}
+	if msg.Opcode != query.Opcode {
+		mismatches = append(mismatches, fmt.Sprintf("opcode %s != sent %s", dns.OpcodeToString[msg.Opcode], dns.OpcodeToString[query.Opcode]))
+	}
+	if len(msg.Question) != len(query.Question) {
+		return append(mismatches, fmt.Sprintf("%d questions != sent %d", len(msg.Question), len(query.Question)))
+	}
+	for i, q := range query.Question {
+		got := msg.Question[i]
+		switch {
+		case got.Name == q.Name:
+		case strings.EqualFold(got.Name, q.Name):
+			mismatches = append(mismatches, fmt.Sprintf("qname case %s != sent %s", got.Name, q.Name))
+		default:
+			mismatches = append(mismatches, fmt.Sprintf("qname %s != sent %s", got.Name, q.Name))
+		}
+		if got.Qtype != q.Qtype {
+			mismatches = append(mismatches, fmt.Sprintf("qtype %s != sent %s", dns.TypeToString[got.Qtype], dns.TypeToString[q.Qtype]))
+		}
+		if got.Qclass != q.Qclass {
+			mismatches = append(mismatches, fmt.Sprintf("qclass %s != sent %s", dns.ClassToString[got.Qclass], dns.ClassToString[q.Qclass]))
+		}
+	}
+	return mismatches
+}
+
+// extractECS 提取响应中的ECS选项
+func extractECS(msg *dns.Msg) *types.ECSInfo {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		if subnet, ok := o.(*dns.EDNS0_SUBNET); ok {
+			return &types.ECSInfo{
+				Subnet: fmt.Sprintf("%s/%d", subnet.Address, subnet.SourceNetmask),
+				Scope:  subnet.SourceScope,
+			}
+		}
+	}
+	return nil
+}
+
+// extractEDNS 提取响应中的EDNS0信息，响应不带OPT记录时返回nil
+func extractEDNS(msg *dns.Msg) *types.EDNSInfo {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	info := &types.EDNSInfo{
+		Version: opt.Version(),
+		UDPSize: opt.UDPSize(),
+		DO:      opt.Do(),
+	}
+	for _, o := range opt.Option {
+		switch v := o.(type) {
+		case *dns.EDNS0_NSID:
+			info.NSID = printableNSID(v.Nsid)
+		case *dns.EDNS0_COOKIE:
+			// 前8字节为客户端cookie，其余为服务器cookie
+			if len(v.Cookie) >= 16 {
+				info.ClientCookie = v.Cookie[:16]
+				info.ServerCookie = v.Cookie[16:]
+			} else {
+				info.ClientCookie = v.Cookie
+			}
+		}
+	}
+	return info
+}
+
+// printableNSID 将十六进制的NSID转为文本，含不可打印字符时保留十六进制
+func printableNSID(nsid string) string {
+	raw, err := hex.DecodeString(nsid)
+	if err != nil {
+		return nsid
+	}
+	for _, c := range raw {
+		if c < 0x20 || c > 0x7e {
+			return nsid
+		}
+	}
+	return string(raw)
+}
+
+// getDNSStatusText 获取DNS状态码的文本描述
+func getDNSStatusText(rcode int) string {
+	switch rcode {
+	case dns.RcodeSuccess:
+		return "Success"
+	case dns.RcodeFormatError:
+		return "Format Error"
+	case dns.RcodeServerFailure:
+		return "Server Failure"
+	case dns.RcodeNameError:
+		return "Name Error (NXDOMAIN)"
+	case dns.RcodeNotImplemented:
+		return "Not Implemented"
+	case dns.RcodeRefused:
+		return "Refused"
+	case dns.RcodeYXDomain:
+		return "YX Domain"
+	case dns.RcodeYXRrset:
+		return "YX RRset"
+	case dns.RcodeNXRrset:
+		return "NX RRset"
+	case dns.RcodeNotAuth:
+		return "Not Auth"
+	case dns.RcodeNotZone:
+		return "Not Zone"
+	default:
+		return fmt.Sprintf("Unknown Error (%d)", rcode)
+	}
+}
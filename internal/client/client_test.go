@@ -0,0 +1,200 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/JaveleyQAQ/geodns/internal/backend"
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// ednsReply 构建带给定EDNS0选项的响应，经过打包和解析以模拟线上收到的报文
+func ednsReply(t *testing.T, do bool, options ...dns.EDNS0) *dns.Msg {
+	t.Helper()
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	m.Response = true
+	m.SetEdns0(1232, do)
+	opt := m.IsEdns0()
+	opt.Option = append(opt.Option, options...)
+	wire, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := new(dns.Msg)
+	if err := resp.Unpack(wire); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestExtractEDNS(t *testing.T) {
+	const client, server = "0102030405060708", "1112131415161718"
+	tests := []struct {
+		name    string
+		do      bool
+		options []dns.EDNS0
+		want    types.EDNSInfo
+	}{
+		{"plain", false, nil, types.EDNSInfo{UDPSize: 1232}},
+		{"do", true, nil, types.EDNSInfo{UDPSize: 1232, DO: true}},
+		{
+			"printable nsid",
+			false,
+			[]dns.EDNS0{&dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: hex.EncodeToString([]byte("ns1.fra"))}},
+			types.EDNSInfo{UDPSize: 1232, NSID: "ns1.fra"},
+		},
+		{
+			"binary nsid",
+			false,
+			[]dns.EDNS0{&dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: "00ff10"}},
+			types.EDNSInfo{UDPSize: 1232, NSID: "00ff10"},
+		},
+		{
+			"client cookie",
+			false,
+			[]dns.EDNS0{&dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: client}},
+			types.EDNSInfo{UDPSize: 1232, ClientCookie: client},
+		},
+		{
+			"server cookie",
+			false,
+			[]dns.EDNS0{&dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: client + server}},
+			types.EDNSInfo{UDPSize: 1232, ClientCookie: client, ServerCookie: server},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractEDNS(ednsReply(t, tt.do, tt.options...))
+			if got == nil {
+				t.Fatal("no EDNS info")
+			}
+			if *got != tt.want {
+				t.Errorf("EDNS = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	if info := extractEDNS(m); info != nil {
+		t.Errorf("response without OPT: EDNS = %+v, want nil", *info)
+	}
+}
+
+func TestMismatchedReplyRecorded(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &dns.Server{Listener: ln, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Id++
+		m.Question[0].Name = "attacker.example."
+		w.WriteMsg(m)
+	})}
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go srv.ActivateAndServe()
+	<-started
+	defer srv.Shutdown()
+
+	addr := ln.Addr().String()
+	b, err := backend.NewPlainBackend([]string{addr}, "tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(b, RetryPolicy{}, nil)
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	ch := make(chan types.RegionResult, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	c.QueryRegion(context.Background(), "example.com", addr, m, &wg, ch)
+	res := <-ch
+
+	if res.Error != "" {
+		t.Fatalf("error = %q, want the reply with mismatches", res.Error)
+	}
+	if len(res.Mismatches) != 2 {
+		t.Errorf("mismatches = %q, want the id and the qname", res.Mismatches)
+	}
+}
+
+// sectionsBackend 返回带授权段、附加段和头部标志的响应
+type sectionsBackend struct {
+	rcode int
+}
+
+func (sectionsBackend) Name() string      { return "sections" }
+func (sectionsBackend) Regions() []string { return []string{"r"} }
+
+func (b sectionsBackend) Exchange(_ context.Context, _ string, msg *dns.Msg) ([]byte, error) {
+	resp := new(dns.Msg)
+	resp.SetRcode(msg, b.rcode)
+	resp.Authoritative = true
+	resp.RecursionAvailable = true
+	resp.AuthenticatedData = true
+	resp.CheckingDisabled = true
+	if b.rcode == dns.RcodeSuccess {
+		resp.Answer = append(resp.Answer, mustRR("example.com. 60 IN A 192.0.2.1"))
+		resp.Ns = append(resp.Ns, mustRR("example.com. 3600 IN NS ns1.example.com."))
+		resp.Extra = append(resp.Extra, mustRR("ns1.example.com. 3600 IN A 192.0.2.53"))
+	} else {
+		resp.Ns = append(resp.Ns, mustRR("example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300"))
+	}
+	resp.SetEdns0(1232, false)
+	return resp.Pack()
+}
+
+func mustRR(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+func TestSectionsAndFlags(t *testing.T) {
+	for _, rcode := range []int{dns.RcodeSuccess, dns.RcodeNameError} {
+		t.Run(dns.RcodeToString[rcode], func(t *testing.T) {
+			c := NewClient(sectionsBackend{rcode: rcode}, RetryPolicy{}, nil)
+			m := new(dns.Msg)
+			m.SetQuestion("example.com.", dns.TypeA)
+			ch := make(chan types.RegionResult, 1)
+			var wg sync.WaitGroup
+			wg.Add(1)
+			c.QueryRegion(context.Background(), "example.com", "r", m, &wg, ch)
+			res := <-ch
+
+			want := types.Flags{AA: true, RA: true, AD: true, CD: true}
+			if res.Flags == nil || *res.Flags != want {
+				t.Errorf("flags = %+v, want %+v", res.Flags, want)
+			}
+			if res.Size != len(res.RawBytes) || res.Size == 0 {
+				t.Errorf("size = %d, raw bytes = %d", res.Size, len(res.RawBytes))
+			}
+			// OPT伪记录不计入附加段
+			if rcode == dns.RcodeSuccess {
+				if len(res.Authority) != 1 || res.Authority[0].Type != "NS" {
+					t.Errorf("authority = %+v, want one NS", res.Authority)
+				}
+				if len(res.Additional) != 1 || res.Additional[0].Value != "192.0.2.53" {
+					t.Errorf("additional = %+v, want the glue A record", res.Additional)
+				}
+			} else {
+				if len(res.Authority) != 1 || res.Authority[0].Type != "SOA" {
+					t.Errorf("authority = %+v, want the SOA", res.Authority)
+				}
+				if len(res.Additional) != 0 {
+					t.Errorf("additional = %+v, want none", res.Additional)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/JaveleyQAQ/geodns/internal/backend"
+	"github.com/JaveleyQAQ/geodns/internal/types"
+)
+
+// errMalformed 响应体无法解析为DNS报文，由 backend.ClassifyError 归类
+var errMalformed = backend.ErrMalformed
+
+// errCircuitOpen 区域处于熔断冷却期
+var errCircuitOpen = errors.New("circuit open, region skipped")
+
+// classifyError 将查询错误归类，返回错误类别和HTTP状态码（非HTTP错误为0）
+func classifyError(err error) (string, int) {
+	if errors.Is(err, errCircuitOpen) {
+		return types.ErrorSkipped, 0
+	}
+	return backend.ClassifyError(err)
+}
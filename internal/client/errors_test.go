@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/backend"
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// failingBackend 按区域名返回对应类别的错误或响应
+type failingBackend struct{}
+
+func (failingBackend) Name() string { return "failing" }
+
+func (failingBackend) Regions() []string {
+	return []string{"ok", "nxdomain", "timeout", "connect", "tls", "http", "malformed", "other"}
+}
+
+func (failingBackend) Exchange(_ context.Context, region string, msg *dns.Msg) ([]byte, error) {
+	resp := new(dns.Msg)
+	resp.SetReply(msg)
+	switch region {
+	case "nxdomain":
+		resp.Rcode = dns.RcodeNameError
+	case "timeout":
+		return nil, fmt.Errorf("read: %w", context.DeadlineExceeded)
+	case "connect":
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	case "tls":
+		return nil, x509.UnknownAuthorityError{}
+	case "http":
+		return nil, &backend.HTTPStatusError{StatusCode: 503, Status: "503 Service Unavailable"}
+	case "malformed":
+		return []byte{0x12, 0x34, 0x56}, nil
+	case "other":
+		return nil, fmt.Errorf("something else")
+	}
+	return resp.Pack()
+}
+
+func TestErrorClasses(t *testing.T) {
+	c := NewClient(failingBackend{}, RetryPolicy{}, nil)
+	want := map[string]struct {
+		class      string
+		httpStatus int
+		rcode      string
+	}{
+		"ok":        {"", 0, "NOERROR"},
+		"nxdomain":  {types.ErrorRcode, 0, "NXDOMAIN"},
+		"timeout":   {types.ErrorTimeout, 0, ""},
+		"connect":   {types.ErrorConnect, 0, ""},
+		"tls":       {types.ErrorTLS, 0, ""},
+		"http":      {types.ErrorHTTPStatus, 503, ""},
+		"malformed": {types.ErrorMalformed, 0, ""},
+		"other":     {types.ErrorOther, 0, ""},
+	}
+	for _, region := range c.Regions() {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		ch := make(chan types.RegionResult, 1)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		c.QueryRegion(context.Background(), "example.com", region, m, &wg, ch)
+		res := <-ch
+
+		w := want[region]
+		if res.ErrorClass != w.class || res.HTTPStatus != w.httpStatus || res.Rcode != w.rcode {
+			t.Errorf("%s: class = %q, http status = %d, rcode = %q, want %q, %d, %q",
+				region, res.ErrorClass, res.HTTPStatus, res.Rcode, w.class, w.httpStatus, w.rcode)
+		}
+		if (res.Error == "") != (w.class == "") {
+			t.Errorf("%s: error = %q", region, res.Error)
+		}
+	}
+}
+
+func TestCircuitOpenIsSkipped(t *testing.T) {
+	c := NewClient(failingBackend{}, RetryPolicy{}, NewCircuitBreaker(1, time.Minute))
+	var classes []string
+	for i := 0; i < 2; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		ch := make(chan types.RegionResult, 1)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		c.QueryRegion(context.Background(), "example.com", "connect", m, &wg, ch)
+		classes = append(classes, (<-ch).ErrorClass)
+	}
+	if classes[0] != types.ErrorConnect || classes[1] != types.ErrorSkipped {
+		t.Errorf("classes = %v, want [connect skipped]", classes)
+	}
+}
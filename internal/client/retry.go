@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/backend"
+)
+
+// RetryPolicy 区域查询失败时的重试策略
+type RetryPolicy struct {
+	Retries   int           // 最大重试次数，0为不重试
+	BaseDelay time.Duration // 首次重试的基础等待时间
+	MaxDelay  time.Duration // 单次等待的上限，同时限制指数退避和Retry-After
+}
+
+// DefaultRetryPolicy 默认重试策略，默认不重试以保持原有的请求数和耗时
+var DefaultRetryPolicy = RetryPolicy{
+	Retries:   0,
+	BaseDelay: 500 * time.Millisecond,
+	MaxDelay:  5 * time.Second,
+}
+
+// backoff 计算第attempt次重试前的等待时间：优先使用Retry-After，否则为带抖动的指数退避，均不超过MaxDelay
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	var statusErr *backend.HTTPStatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		// 服务器指定的等待时间可能很长，超过上限时按上限等待，避免一个区域拖住整个查询
+		if p.MaxDelay > 0 && statusErr.RetryAfter > p.MaxDelay {
+			return p.MaxDelay
+		}
+		return statusErr.RetryAfter
+	}
+
+	delay := p.BaseDelay << attempt
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	// 在 [delay/2, delay) 之间随机抖动，避免各区域同时重试
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+// retryable 判断错误是否值得重试：HTTP 4xx（429和408除外）、context取消和预算用完不重试
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, backend.ErrBudgetExhausted) {
+		return false
+	}
+	var statusErr *backend.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusRequestTimeout:
+			return true
+		}
+		return statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+// wait 等待指定时间，context结束时提前返回错误
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// breakerState 单个区域的熔断状态
+type breakerState struct {
+	fails     int
+	openUntil time.Time
+	probing   bool // 冷却结束后已放行一次试探请求，结果返回前其他请求仍被跳过
+}
+
+// CircuitBreaker 按区域的熔断器：连续失败达到阈值后，在冷却时间内跳过该区域；
+// 冷却结束后进入半开状态，只放行一次试探请求，成功则关闭熔断，失败则重新开始冷却
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	regions   map[string]*breakerState
+}
+
+// NewCircuitBreaker 创建熔断器，threshold<=0时返回nil（不熔断）
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		regions:   make(map[string]*breakerState),
+	}
+}
+
+// Allow 判断区域当前是否允许查询，冷却结束后只放行一次试探请求
+func (cb *CircuitBreaker) Allow(region string) bool {
+	if cb == nil {
+		return true
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	state, ok := cb.regions[region]
+	if !ok || state.fails < cb.threshold {
+		return true
+	}
+	if state.probing || time.Now().Before(state.openUntil) {
+		return false
+	}
+	state.probing = true
+	return true
+}
+
+// Record 记录区域查询结果，返回本次失败是否触发（或在试探失败后重新触发）熔断
+func (cb *CircuitBreaker) Record(region string, err error) bool {
+	if cb == nil {
+		return false
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if err == nil {
+		delete(cb.regions, region)
+		return false
+	}
+	state, ok := cb.regions[region]
+	if !ok {
+		state = &breakerState{}
+		cb.regions[region] = state
+	}
+	state.fails++
+	state.probing = false
+	if state.fails >= cb.threshold {
+		state.openUntil = time.Now().Add(cb.cooldown)
+		return true
+	}
+	return false
+}
+
+// Release 放弃本次请求的结果（如预算用完），不计入失败，半开状态下允许下一次试探
+func (cb *CircuitBreaker) Release(region string) {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if state, ok := cb.regions[region]; ok {
+		state.probing = false
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/backend"
+)
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	cb := NewCircuitBreaker(2, 20*time.Millisecond)
+	fail := errors.New("fail")
+
+	if cb.Record("r", fail) {
+		t.Fatal("opened before reaching the threshold")
+	}
+	if !cb.Record("r", fail) {
+		t.Fatal("did not open at the threshold")
+	}
+	if cb.Allow("r") {
+		t.Fatal("allowed during cooldown")
+	}
+	if !cb.Allow("other") {
+		t.Fatal("blocked an unrelated region")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !cb.Allow("r") {
+		t.Fatal("probe not allowed after cooldown")
+	}
+	if cb.Allow("r") {
+		t.Fatal("second request allowed while the probe is in flight")
+	}
+
+	// 试探失败后重新冷却
+	if !cb.Record("r", fail) {
+		t.Fatal("failed probe did not reopen the circuit")
+	}
+	if cb.Allow("r") {
+		t.Fatal("allowed right after a failed probe")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !cb.Allow("r") {
+		t.Fatal("probe not allowed after second cooldown")
+	}
+	cb.Record("r", nil)
+	if !cb.Allow("r") || !cb.Allow("r") {
+		t.Fatal("circuit not closed after a successful probe")
+	}
+}
+
+func TestCircuitBreakerReleaseProbe(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Millisecond)
+	cb.Record("r", errors.New("fail"))
+	time.Sleep(5 * time.Millisecond)
+
+	if !cb.Allow("r") {
+		t.Fatal("probe not allowed after cooldown")
+	}
+	cb.Release("r")
+	if !cb.Allow("r") {
+		t.Fatal("released probe did not let the next request through")
+	}
+}
+
+func TestBackoffCapsRetryAfter(t *testing.T) {
+	policy := RetryPolicy{Retries: 1, BaseDelay: 10 * time.Millisecond, MaxDelay: 2 * time.Second}
+	tests := []struct {
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{time.Second, time.Second},
+		{time.Hour, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		err := &backend.HTTPStatusError{StatusCode: 429, Status: "429 Too Many Requests", RetryAfter: tt.retryAfter}
+		if got := policy.backoff(0, err); got != tt.want {
+			t.Errorf("Retry-After %v: backoff = %v, want %v", tt.retryAfter, got, tt.want)
+		}
+	}
+
+	// 普通错误的指数退避同样不超过上限
+	for attempt := 0; attempt < 10; attempt++ {
+		if got := policy.backoff(attempt, errors.New("timeout")); got > policy.MaxDelay {
+			t.Errorf("attempt %d: backoff = %v, want at most %v", attempt, got, policy.MaxDelay)
+		}
+	}
+}
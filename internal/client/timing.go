@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/backend"
+	"github.com/JaveleyQAQ/geodns/internal/types"
+)
+
+// timingRecorder 通过httptrace记录一次区域请求各阶段的耗时
+type timingRecorder struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timing       types.Timing
+}
+
+// withTiming 返回挂载了httptrace的context，HTTP类后端的请求会回调记录各阶段耗时。
+// 限速等待结束时重新开始计时，排队时间单独记录
+func withTiming(ctx context.Context) (context.Context, *timingRecorder) {
+	r := &timingRecorder{start: time.Now()}
+	ctx = backend.WithLimiterWaited(ctx, func() {
+		r.mu.Lock()
+		now := time.Now()
+		r.timing.Wait = millis(now.Sub(r.start))
+		r.start = now
+		r.mu.Unlock()
+	})
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.mu.Lock()
+			r.timing.Reused = info.Reused
+			r.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.mu.Lock()
+			r.dnsStart = time.Now()
+			r.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			r.mu.Lock()
+			r.timing.DNSLookup = millis(time.Since(r.dnsStart))
+			r.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			// 多个地址并发拨号时从第一次拨号开始计时
+			r.mu.Lock()
+			if r.connectStart.IsZero() {
+				r.connectStart = time.Now()
+			}
+			r.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			r.mu.Lock()
+			if err == nil && r.timing.Connect == 0 {
+				r.timing.Connect = millis(time.Since(r.connectStart))
+			}
+			r.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			r.mu.Lock()
+			r.tlsStart = time.Now()
+			r.mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			r.mu.Lock()
+			if err == nil {
+				r.timing.TLSHandshake = millis(time.Since(r.tlsStart))
+			}
+			r.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			r.mu.Lock()
+			r.timing.FirstByte = millis(time.Since(r.start))
+			r.mu.Unlock()
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace), r
+}
+
+// finish 结束计时并返回耗时分解，非HTTP后端只有总耗时
+func (r *timingRecorder) finish() *types.Timing {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	timing := r.timing
+	timing.Total = millis(time.Since(r.start))
+	return &timing
+}
+
+// millis 将耗时转换为毫秒，保留两位小数
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()/10) / 100
+}
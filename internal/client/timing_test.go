@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/JaveleyQAQ/geodns/internal/backend"
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// answerBackend 立即返回空应答
+type answerBackend struct{}
+
+func (answerBackend) Name() string      { return "answer" }
+func (answerBackend) Regions() []string { return []string{"r"} }
+
+func (answerBackend) Exchange(_ context.Context, _ string, msg *dns.Msg) ([]byte, error) {
+	resp := new(dns.Msg)
+	resp.SetReply(msg)
+	return resp.Pack()
+}
+
+func TestTimingExcludesLimiterWait(t *testing.T) {
+	limited := backend.NewRateLimitedBackend(answerBackend{}, backend.RateLimits{Global: 1}, nil)
+	c := NewClient(limited, RetryPolicy{}, nil)
+
+	var results []types.RegionResult
+	for i := 0; i < 2; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.com.", dns.TypeA)
+		ch := make(chan types.RegionResult, 1)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		c.QueryRegion(context.Background(), "example.com", "r", m, &wg, ch)
+		results = append(results, <-ch)
+	}
+
+	// 第二次请求在限速器中排队约1秒，排队时间只计入wait
+	second := results[1].Timing
+	if second == nil {
+		t.Fatal("no timing recorded")
+	}
+	if second.Wait < 500 {
+		t.Errorf("wait = %vms, want the limiter delay", second.Wait)
+	}
+	if second.Total > 200 {
+		t.Errorf("total = %vms, includes the limiter delay", second.Total)
+	}
+}
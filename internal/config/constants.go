@@ -33,4 +33,17 @@ const (
 const (
 	ModeVercel     = "vercel"
 	ModeCloudflare = "cloudflare"
+	ModeDoH        = "doh"
+	ModeDoHJSON    = "doh-json"
+	ModeUDP        = "udp"
+	ModeTCP        = "tcp"
+	ModeDoT        = "dot"
+	ModeDoQ        = "doq"
+	ModeIterative  = "iterative"
 )
+
+// Modes 支持的查询后端
+var Modes = []string{ModeVercel, ModeCloudflare, ModeDoH, ModeDoHJSON, ModeUDP, ModeTCP, ModeDoT, ModeDoQ, ModeIterative}
+
+// HTTPModes 基于HTTP的查询后端，只有这些后端支持代理
+var HTTPModes = []string{ModeVercel, ModeCloudflare, ModeDoH, ModeDoHJSON}
@@ -0,0 +1,23 @@
+package config
+
+// DefaultECSSubnets 内置的国家/地区代码到代表性子网的映射（各国主要运营商的地址段），
+// 用于ECS地理模拟，可通过 -ecs-table 指定自定义表覆盖
+var DefaultECSSubnets = map[string]string{
+	"AU": "1.120.0.0/24",   // Telstra
+	"BR": "200.160.0.0/24", // NIC.br
+	"CA": "99.224.0.0/24",  // Rogers
+	"CN": "123.125.0.0/24", // China Unicom Beijing
+	"DE": "91.0.0.0/24",    // Deutsche Telekom
+	"EG": "41.32.0.0/24",   // TE Data
+	"FR": "90.0.0.0/24",    // Orange
+	"GB": "86.128.0.0/24",  // BT
+	"ID": "36.64.0.0/24",   // Telkom Indonesia
+	"IN": "49.32.0.0/24",   // Reliance Jio
+	"JP": "126.0.0.0/24",   // SoftBank
+	"KE": "105.160.0.0/24", // Safaricom
+	"NG": "105.112.0.0/24", // Airtel Nigeria
+	"RU": "95.24.0.0/24",   // Beeline
+	"TR": "78.160.0.0/24",  // Turk Telekom
+	"US": "73.0.0.0/24",    // Comcast
+	"VN": "113.160.0.0/24", // VNPT
+}
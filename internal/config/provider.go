@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 )
 
 // DNSProvider DNS服务提供商
@@ -17,7 +18,6 @@ type Resolver struct {
 	Code string
 }
 
-var Provider *DNSProvider
 var Mode string
 var CurrentResolver *Resolver
 var Verbose bool // 添加调试模式变量
@@ -29,6 +29,32 @@ var SupportedResolvers = map[string]*Resolver{
 	"cloudflare": {Name: "Cloudflare", Code: "cloudflare"},
 }
 
+// VercelPath 和 CloudflarePath 为dns.surf两种接口的请求路径格式，拼接在地址之后
+const (
+	VercelPath     = "/api/region/%s?dns=%s&resolver=%s&region=%s&_=%.16f"
+	CloudflarePath = "/?dns=%s&region=%s&_=%.16f"
+)
+
+// VercelProvider vercel.dns.surf 区域配置
+var VercelProvider = &DNSProvider{
+	BaseURL: "https://vercel.dns.surf" + VercelPath,
+	Regions: []string{
+		"hnd1", "kix1", "sin1", "icn1", "bom1", "syd1", "cpt1",
+		"arn1", "dub1", "lhr1", "fra1", "cdg1", "hkg1",
+	},
+}
+
+// CloudflareProvider cloudflare.dns.surf 区域配置
+var CloudflareProvider = &DNSProvider{
+	BaseURL: "https://cloudflare.dns.surf" + CloudflarePath,
+	Regions: []string{
+		"ams", "arn", "bom", "cdg", "cle", "den", "dfw", "ewr", "fra", "gru", "hkg", "iad",
+		"jfk", "lax", "lhr", "mad", "man", "nrt", "ord", "otp", "par", "sea", "sgp", "sin",
+		"sfo", "syd", "tpe", "yul", "yyz", "zag", "zur", "mex", "maa", "del", "dac", "ccu",
+		"khi", "isb", "tun", "jnb", "cpt", "los", "abuja", "kgl", "mpm", "dar", "nbo", "acc",
+	},
+}
+
 func init() {
 	Mode = os.Getenv("DNS_MODE")
 	if Mode == "" {
@@ -38,27 +64,19 @@ func init() {
 	// 默认使用cloudflare解析器
 	CurrentResolver = SupportedResolvers["cloudflare"]
 
-	if Mode == ModeVercel {
-		Provider = &DNSProvider{
-			BaseURL: "https://vercel.dns.surf/api/region/%s?dns=%s&resolver=%s&region=%s&_=%.16f",
-			Regions: []string{
-				"hnd1", "kix1", "sin1", "icn1", "bom1", "syd1", "cpt1",
-				"arn1", "dub1", "lhr1", "fra1", "cdg1", "hkg1",
-			},
-		}
-	} else {
-		Provider = &DNSProvider{
-			BaseURL: "https://cloudflare.dns.surf/?dns=%s&region=%s&_=%.16f",
-			Regions: []string{
-				"ams", "arn", "bom", "cdg", "cle", "den", "dfw", "ewr", "fra", "gru", "hkg", "iad",
-				"jfk", "lax", "lhr", "mad", "man", "nrt", "ord", "otp", "par", "sea", "sgp", "sin",
-				"sfo", "syd", "tpe", "yul", "yyz", "zag", "zur", "mex", "maa", "del", "dac", "ccu",
-				"khi", "isb", "tun", "jnb", "cpt", "los", "abuja", "kgl", "mpm", "dar", "nbo", "acc",
-			},
-		}
+	// DNS_SURF_URL 可将两种接口指向其他地址（如本地模拟服务器）
+	if base := os.Getenv("DNS_SURF_URL"); base != "" {
+		SetSurfBaseURL(base)
 	}
 }
 
+// SetSurfBaseURL 将vercel和cloudflare接口的地址替换为base，路径格式保持不变
+func SetSurfBaseURL(base string) {
+	base = strings.TrimRight(base, "/")
+	VercelProvider.BaseURL = base + VercelPath
+	CloudflareProvider.BaseURL = base + CloudflarePath
+}
+
 // SetResolver 设置解析器
 func SetResolver(resolverName string) error {
 	if resolver, exists := SupportedResolvers[resolverName]; exists {
@@ -0,0 +1,214 @@
+package dnssec
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// nsec3OptOut NSEC3 的 Opt-Out 标志位
+const nsec3OptOut = 1
+
+// denialRecords 否定应答中的NSEC和NSEC3记录
+type denialRecords struct {
+	nsec  []*dns.NSEC
+	nsec3 []*dns.NSEC3
+}
+
+// collectDenial 提取记录中的NSEC/NSEC3，调用方需先验证它们的签名
+func collectDenial(records []dns.RR) denialRecords {
+	var d denialRecords
+	for _, rr := range records {
+		switch r := rr.(type) {
+		case *dns.NSEC:
+			d.nsec = append(d.nsec, r)
+		case *dns.NSEC3:
+			d.nsec3 = append(d.nsec3, r)
+		}
+	}
+	return d
+}
+
+// proveDenial 检查否定应答的NSEC/NSEC3是否确实证明了查询名不存在（NXDOMAIN）或不存在该类型（NODATA）
+func proveDenial(qname string, qtype uint16, rcode int, d denialRecords) error {
+	switch rcode {
+	case dns.RcodeNameError:
+		if d.nxdomainNSEC(qname) || d.nxdomainNSEC3(qname) {
+			return nil
+		}
+		return fmt.Errorf("NSEC/NSEC3 records do not prove %s does not exist", qname)
+	case dns.RcodeSuccess:
+		if d.nodataNSEC(qname, qtype) || d.nodataNSEC3(qname, qtype) {
+			return nil
+		}
+		return fmt.Errorf("NSEC/NSEC3 records do not prove %s has no %s", qname, dns.TypeToString[qtype])
+	}
+	return fmt.Errorf("cannot prove %s response", dns.RcodeToString[rcode])
+}
+
+// proveNoDS 检查父区的NSEC/NSEC3是否证明委派点zone没有DS（包括NSEC3 Opt-Out覆盖的不安全委派）
+func proveNoDS(zone string, d denialRecords) error {
+	for _, nsec := range d.nsec {
+		// 委派点的NSEC属于父区，带SOA位的是子区顶点的NSEC，不能证明父区没有DS
+		if strings.EqualFold(nsec.Hdr.Name, zone) && !hasType(nsec.TypeBitMap, dns.TypeDS) && !hasType(nsec.TypeBitMap, dns.TypeSOA) {
+			return nil
+		}
+	}
+	for _, nsec3 := range d.nsec3 {
+		if nsec3.Match(zone) && !hasType(nsec3.TypeBitMap, dns.TypeDS) && !hasType(nsec3.TypeBitMap, dns.TypeSOA) {
+			return nil
+		}
+	}
+	if _, nextCloser, ok := d.closestEncloser(zone); ok && nextCloser != "" {
+		for _, nsec3 := range d.nsec3 {
+			if nsec3.Cover(nextCloser) && nsec3.Flags&nsec3OptOut != 0 {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no signed NSEC/NSEC3 proof that %s has no DS", zone)
+}
+
+// nxdomainNSEC 存在覆盖查询名的NSEC，且最近祖先下的通配符也被覆盖
+func (d denialRecords) nxdomainNSEC(qname string) bool {
+	for _, nsec := range d.nsec {
+		if !nsecCovers(nsec, qname) {
+			continue
+		}
+		wildcard := wildcardOf(nsecEncloser(nsec, qname))
+		for _, w := range d.nsec {
+			if nsecCovers(w, wildcard) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nodataNSEC 查询名的NSEC不含该类型和CNAME，或通配符NODATA：查询名被覆盖且匹配的通配符NSEC不含该类型
+func (d denialRecords) nodataNSEC(qname string, qtype uint16) bool {
+	for _, nsec := range d.nsec {
+		if strings.EqualFold(nsec.Hdr.Name, qname) {
+			return !hasType(nsec.TypeBitMap, qtype) && !hasType(nsec.TypeBitMap, dns.TypeCNAME)
+		}
+	}
+	for _, nsec := range d.nsec {
+		if !nsecCovers(nsec, qname) {
+			continue
+		}
+		wildcard := wildcardOf(nsecEncloser(nsec, qname))
+		for _, w := range d.nsec {
+			if strings.EqualFold(w.Hdr.Name, wildcard) && !hasType(w.TypeBitMap, qtype) && !hasType(w.TypeBitMap, dns.TypeCNAME) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nxdomainNSEC3 最近祖先证明：最近祖先匹配、下一级名称被覆盖，且最近祖先下的通配符被覆盖
+func (d denialRecords) nxdomainNSEC3(qname string) bool {
+	ce, nextCloser, ok := d.closestEncloser(qname)
+	if !ok || nextCloser == "" || !d.nsec3Covers(nextCloser) {
+		return false
+	}
+	return d.nsec3Covers(wildcardOf(ce))
+}
+
+// nodataNSEC3 查询名的NSEC3不含该类型和CNAME，或通配符NODATA：最近祖先证明成立且通配符的NSEC3不含该类型
+func (d denialRecords) nodataNSEC3(qname string, qtype uint16) bool {
+	for _, nsec3 := range d.nsec3 {
+		if nsec3.Match(qname) {
+			return !hasType(nsec3.TypeBitMap, qtype) && !hasType(nsec3.TypeBitMap, dns.TypeCNAME)
+		}
+	}
+	ce, nextCloser, ok := d.closestEncloser(qname)
+	if !ok || nextCloser == "" || !d.nsec3Covers(nextCloser) {
+		return false
+	}
+	for _, nsec3 := range d.nsec3 {
+		if nsec3.Match(wildcardOf(ce)) && !hasType(nsec3.TypeBitMap, qtype) && !hasType(nsec3.TypeBitMap, dns.TypeCNAME) {
+			return true
+		}
+	}
+	return false
+}
+
+// closestEncloser 查找有匹配NSEC3的最近祖先，返回祖先和其下包含查询名的下一级名称（名称本身匹配时为空）
+func (d denialRecords) closestEncloser(name string) (string, string, bool) {
+	nextCloser := ""
+	for ce := dns.Fqdn(name); ; ce = parentName(ce) {
+		for _, nsec3 := range d.nsec3 {
+			if nsec3.Match(ce) {
+				return ce, nextCloser, true
+			}
+		}
+		if ce == "." {
+			return "", "", false
+		}
+		nextCloser = ce
+	}
+}
+
+// nsec3Covers 是否有NSEC3覆盖该名称的散列
+func (d denialRecords) nsec3Covers(name string) bool {
+	for _, nsec3 := range d.nsec3 {
+		if nsec3.Cover(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// nsecCovers NSEC的 (owner, next) 区间是否按规范顺序严格包含该名称，区域最后一条NSEC的next回绕到顶点
+func nsecCovers(nsec *dns.NSEC, name string) bool {
+	owner, next := nsec.Hdr.Name, nsec.NextDomain
+	if canonicalCompare(owner, next) < 0 {
+		return canonicalCompare(owner, name) < 0 && canonicalCompare(name, next) < 0
+	}
+	return canonicalCompare(owner, name) < 0 && dns.IsSubDomain(next, name)
+}
+
+// nsecEncloser 由覆盖查询名的NSEC推出最近祖先：与owner或next共同的最长祖先
+func nsecEncloser(nsec *dns.NSEC, qname string) string {
+	ce := parentName(dns.Fqdn(qname))
+	for ce != "." && !dns.IsSubDomain(ce, nsec.Hdr.Name) && !dns.IsSubDomain(ce, nsec.NextDomain) {
+		ce = parentName(ce)
+	}
+	return ce
+}
+
+// wildcardOf 名称下的通配符名称
+func wildcardOf(name string) string {
+	if name == "." {
+		return "*."
+	}
+	return "*." + name
+}
+
+// parentName 去掉名称最左边的标签，根的父名称仍为根
+func parentName(name string) string {
+	off, end := dns.NextLabel(name, 0)
+	if end {
+		return "."
+	}
+	return name[off:]
+}
+
+// canonicalCompare 按RFC 4034 6.1的规范顺序比较名称：从右向左逐个标签比较，忽略大小写
+func canonicalCompare(a, b string) int {
+	la := dns.SplitDomainName(strings.ToLower(a))
+	lb := dns.SplitDomainName(strings.ToLower(b))
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := strings.Compare(la[i], lb[j]); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
+
+func hasType(bitmap []uint16, t uint16) bool {
+	return slices.Contains(bitmap, t)
+}
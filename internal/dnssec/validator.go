@@ -0,0 +1,372 @@
+package dnssec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/backend"
+	"github.com/JaveleyQAQ/geodns/internal/config"
+	"github.com/JaveleyQAQ/geodns/internal/query"
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// errInsecure 委派链上缺少DS，区域不在信任链内
+var errInsecure = errors.New("insecure delegation")
+
+// fetchError 获取验证所需记录失败，结果无法判定
+type fetchError struct {
+	err error
+}
+
+func (e *fetchError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fetchError) Unwrap() error {
+	return e.err
+}
+
+// Validator 本地DNSSEC验证器，通过同一区域查询DNSKEY/DS，逐级验证到根信任锚
+type Validator struct {
+	backend backend.Backend
+	anchors []*dns.DS
+
+	mu    sync.Mutex
+	cache map[string]*dns.Msg
+}
+
+// NewValidator 创建验证器，验证所需的记录通过b按区域查询并在本次运行内缓存
+func NewValidator(b backend.Backend) *Validator {
+	v := &Validator{
+		backend: b,
+		cache:   make(map[string]*dns.Msg),
+	}
+	for _, s := range config.RootTrustAnchors {
+		if rr, err := dns.NewRR(s); err == nil {
+			v.anchors = append(v.anchors, rr.(*dns.DS))
+		}
+	}
+	return v
+}
+
+// PrepareQuery 为查询报文设置DO位，使上游返回RRSIG
+func PrepareQuery(m *dns.Msg) {
+	if opt := m.IsEdns0(); opt != nil {
+		opt.SetDo()
+		return
+	}
+	m.SetEdns0(query.DefaultUDPSize, true)
+}
+
+// Validate 验证区域响应，返回验证结果和原因
+func (v *Validator) Validate(ctx context.Context, region string, msg *dns.Msg) (string, string) {
+	records := msg.Answer
+	negative := msg.Rcode != dns.RcodeSuccess || len(msg.Answer) == 0
+	if negative {
+		// 否定应答验证权威部分的NSEC/NSEC3和SOA签名，签名通过后再检查它们是否覆盖查询名
+		records = msg.Ns
+	}
+	rrsets, sigs := groupRRsets(records)
+	if len(rrsets) == 0 {
+		return types.DNSSECIndeterminate, "no records to validate"
+	}
+
+	keys := make([]string, 0, len(rrsets))
+	for key := range rrsets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	status := types.DNSSECSecure
+	var reasons []string
+	for _, key := range keys {
+		rrset := rrsets[key]
+		var err error
+		if len(sigs[key]) == 0 {
+			err = v.checkUnsigned(ctx, region, rrset[0].Header().Name)
+		} else {
+			err = v.verifyRRset(ctx, region, rrset, sigs[key])
+		}
+
+		var fe *fetchError
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, errInsecure):
+			if status == types.DNSSECSecure {
+				status = types.DNSSECInsecure
+			}
+		case errors.As(err, &fe):
+			if status != types.DNSSECBogus {
+				status = types.DNSSECIndeterminate
+			}
+		default:
+			status = types.DNSSECBogus
+		}
+		reasons = append(reasons, err.Error())
+	}
+
+	// 签名有效但不能证明查询名或类型不存在的否定应答无法判定
+	if negative && status == types.DNSSECSecure && len(msg.Question) > 0 {
+		q := msg.Question[0]
+		if err := proveDenial(q.Name, q.Qtype, msg.Rcode, collectDenial(msg.Ns)); err != nil {
+			status = types.DNSSECIndeterminate
+			reasons = append(reasons, err.Error())
+		}
+	}
+	return status, strings.Join(reasons, "; ")
+}
+
+// checkUnsigned 检查未签名RRset所在区域，已签名区域的缺失签名视为bogus
+func (v *Validator) checkUnsigned(ctx context.Context, region, name string) error {
+	apex, err := v.zoneApex(ctx, region, name)
+	if err != nil {
+		return err
+	}
+	if _, err := v.trustedKeys(ctx, region, apex); err != nil {
+		return err
+	}
+	return fmt.Errorf("missing RRSIG for %s in signed zone %s", name, apex)
+}
+
+// verifyRRset 使用签名者的可信DNSKEY验证RRset，任一签名通过即可
+func (v *Validator) verifyRRset(ctx context.Context, region string, rrset []dns.RR, sigs []*dns.RRSIG) error {
+	name := rrset[0].Header().Name
+	rtype := dns.TypeToString[rrset[0].Header().Rrtype]
+
+	var lastErr error
+	for _, sig := range sigs {
+		if !dns.IsSubDomain(sig.SignerName, name) {
+			lastErr = fmt.Errorf("%s %s signed by unrelated zone %s", name, rtype, sig.SignerName)
+			continue
+		}
+		if !sig.ValidityPeriod(time.Now()) {
+			lastErr = fmt.Errorf("RRSIG for %s %s expired or not yet valid (%s - %s)", name, rtype,
+				dns.TimeToString(sig.Inception), dns.TimeToString(sig.Expiration))
+			continue
+		}
+
+		keys, err := v.trustedKeys(ctx, region, sig.SignerName)
+		if err != nil {
+			return err
+		}
+		lastErr = fmt.Errorf("no DNSKEY of %s matches RRSIG key tag %d for %s %s", sig.SignerName, sig.KeyTag, name, rtype)
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm {
+				continue
+			}
+			if err := sig.Verify(key, rrset); err != nil {
+				lastErr = fmt.Errorf("RRSIG for %s %s does not verify: %v", name, rtype, err)
+				continue
+			}
+			return nil
+		}
+	}
+	return lastErr
+}
+
+// trustedKeys 返回区域经DS（根区为信任锚）验证的DNSKEY集合
+func (v *Validator) trustedKeys(ctx context.Context, region, zone string) ([]*dns.DNSKEY, error) {
+	var ds []*dns.DS
+	if zone == "." {
+		ds = v.anchors
+	} else {
+		var err error
+		ds, err = v.trustedDS(ctx, region, zone)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := v.fetch(ctx, region, zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*dns.DNSKEY
+	var keySet []dns.RR
+	var sigs []*dns.RRSIG
+	for _, rr := range resp.Answer {
+		switch r := rr.(type) {
+		case *dns.DNSKEY:
+			if strings.EqualFold(r.Hdr.Name, zone) {
+				keys = append(keys, r)
+				keySet = append(keySet, r)
+			}
+		case *dns.RRSIG:
+			if r.TypeCovered == dns.TypeDNSKEY {
+				sigs = append(sigs, r)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no DNSKEY for %s", zone)
+	}
+
+	// DNSKEY集合必须由与DS匹配的密钥签名
+	for _, key := range keys {
+		if !matchesDS(key, ds) {
+			continue
+		}
+		for _, sig := range sigs {
+			if sig.KeyTag == key.KeyTag() && sig.ValidityPeriod(time.Now()) && sig.Verify(key, keySet) == nil {
+				return keys, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("DNSKEY set of %s is not signed by a key matching its DS", zone)
+}
+
+// trustedDS 返回经父区签名验证的DS集合，缺少DS时返回errInsecure
+func (v *Validator) trustedDS(ctx context.Context, region, zone string) ([]*dns.DS, error) {
+	resp, err := v.fetch(ctx, region, zone, dns.TypeDS)
+	if err != nil {
+		return nil, err
+	}
+
+	var ds []*dns.DS
+	var dsSet []dns.RR
+	var sigs []*dns.RRSIG
+	for _, rr := range resp.Answer {
+		switch r := rr.(type) {
+		case *dns.DS:
+			if strings.EqualFold(r.Hdr.Name, zone) {
+				ds = append(ds, r)
+				dsSet = append(dsSet, r)
+			}
+		case *dns.RRSIG:
+			if r.TypeCovered == dns.TypeDS {
+				sigs = append(sigs, r)
+			}
+		}
+	}
+	if len(ds) == 0 {
+		return nil, v.verifyNoDS(ctx, region, zone, resp)
+	}
+	if len(sigs) == 0 {
+		return nil, fmt.Errorf("missing RRSIG for %s DS", zone)
+	}
+	if err := v.verifyRRset(ctx, region, dsSet, sigs); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// verifyNoDS 验证父区对DS不存在的签名证明，证明成立时返回errInsecure，缺少证明时结果无法判定
+func (v *Validator) verifyNoDS(ctx context.Context, region, zone string, resp *dns.Msg) error {
+	rrsets, sigs := groupRRsets(resp.Ns)
+	var proof []dns.RR
+	for key, rrset := range rrsets {
+		rtype := rrset[0].Header().Rrtype
+		if rtype != dns.TypeNSEC && rtype != dns.TypeNSEC3 {
+			continue
+		}
+		// 证明必须由父区签名，子区顶点的NSEC不能证明委派没有DS
+		var parentSigs []*dns.RRSIG
+		for _, sig := range sigs[key] {
+			if !strings.EqualFold(sig.SignerName, zone) {
+				parentSigs = append(parentSigs, sig)
+			}
+		}
+		if len(parentSigs) == 0 {
+			continue
+		}
+		if err := v.verifyRRset(ctx, region, rrset, parentSigs); err != nil {
+			if errors.Is(err, errInsecure) {
+				// 父区本身不安全，子区同样不安全
+				return err
+			}
+			continue
+		}
+		proof = append(proof, rrset...)
+	}
+	if err := proveNoDS(zone, collectDenial(proof)); err != nil {
+		return &fetchError{err}
+	}
+	return fmt.Errorf("%w: no DS for %s", errInsecure, zone)
+}
+
+// zoneApex 通过SOA查询确定名称所在区域的顶点
+func (v *Validator) zoneApex(ctx context.Context, region, name string) (string, error) {
+	resp, err := v.fetch(ctx, region, name, dns.TypeSOA)
+	if err != nil {
+		return "", err
+	}
+	for _, rr := range append(resp.Answer, resp.Ns...) {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Hdr.Name, nil
+		}
+	}
+	return "", &fetchError{fmt.Errorf("no SOA found for %s", name)}
+}
+
+// fetch 通过区域查询验证所需的记录（设置CD位以取得未经上游验证的数据）
+func (v *Validator) fetch(ctx context.Context, region, name string, qtype uint16) (*dns.Msg, error) {
+	key := region + "|" + strings.ToLower(name) + "|" + dns.TypeToString[qtype]
+	v.mu.Lock()
+	cached, ok := v.cache[key]
+	v.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.CheckingDisabled = true
+	m.SetEdns0(query.DefaultUDPSize, true)
+
+	body, err := v.backend.Exchange(ctx, region, m)
+	if err != nil {
+		return nil, &fetchError{fmt.Errorf("fetching %s %s: %w", name, dns.TypeToString[qtype], err)}
+	}
+	resp := new(dns.Msg)
+	if err := resp.Unpack(body); err != nil {
+		return nil, &fetchError{fmt.Errorf("fetching %s %s: %w", name, dns.TypeToString[qtype], err)}
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, &fetchError{fmt.Errorf("fetching %s %s: %s", name, dns.TypeToString[qtype], dns.RcodeToString[resp.Rcode])}
+	}
+
+	v.mu.Lock()
+	v.cache[key] = resp
+	v.mu.Unlock()
+	return resp, nil
+}
+
+// groupRRsets 按名称和类型分组RRset，RRSIG按覆盖的类型归组
+func groupRRsets(records []dns.RR) (map[string][]dns.RR, map[string][]*dns.RRSIG) {
+	rrsets := make(map[string][]dns.RR)
+	sigs := make(map[string][]*dns.RRSIG)
+	for _, rr := range records {
+		name := strings.ToLower(rr.Header().Name)
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			key := name + "|" + dns.TypeToString[sig.TypeCovered]
+			sigs[key] = append(sigs[key], sig)
+			continue
+		}
+		if rr.Header().Rrtype == dns.TypeOPT {
+			continue
+		}
+		key := name + "|" + dns.TypeToString[rr.Header().Rrtype]
+		rrsets[key] = append(rrsets[key], rr)
+	}
+	return rrsets, sigs
+}
+
+// matchesDS 密钥是否与任一DS记录匹配
+func matchesDS(key *dns.DNSKEY, ds []*dns.DS) bool {
+	for _, d := range ds {
+		if d.KeyTag != key.KeyTag() || d.Algorithm != key.Algorithm {
+			continue
+		}
+		if computed := key.ToDS(d.DigestType); computed != nil && strings.EqualFold(computed.Digest, d.Digest) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package dnssec
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// testZone 测试用的签名区域
+type testZone struct {
+	name string
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newTestZone(t *testing.T, name string) *testZone {
+	t.Helper()
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testZone{name: name, key: key, priv: priv.(crypto.Signer)}
+}
+
+// sign 返回RRset和区域对它的签名
+func (z *testZone) sign(t *testing.T, rrset ...dns.RR) []dns.RR {
+	t.Helper()
+	sig := &dns.RRSIG{
+		Algorithm:  z.key.Algorithm,
+		KeyTag:     z.key.KeyTag(),
+		SignerName: z.name,
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+	}
+	if err := sig.Sign(z.priv, rrset); err != nil {
+		t.Fatal(err)
+	}
+	return append(rrset, sig)
+}
+
+// fakeBackend 按 "名称|类型" 返回预设的响应
+type fakeBackend map[string]*dns.Msg
+
+func (b fakeBackend) Name() string      { return "fake" }
+func (b fakeBackend) Regions() []string { return []string{"r"} }
+
+func (b fakeBackend) Exchange(_ context.Context, _ string, msg *dns.Msg) ([]byte, error) {
+	q := msg.Question[0]
+	resp, ok := b[strings.ToLower(q.Name)+"|"+dns.TypeToString[q.Qtype]]
+	if !ok {
+		return nil, fmt.Errorf("unexpected query %s %s", q.Name, dns.TypeToString[q.Qtype])
+	}
+	resp = resp.Copy()
+	resp.SetReply(msg)
+	return resp.Pack()
+}
+
+// add 设置一条查询的响应
+func (b fakeBackend) add(name string, qtype uint16, rcode int, answer, ns []dns.RR) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	resp := new(dns.Msg)
+	resp.SetRcode(m, rcode)
+	resp.Answer = answer
+	resp.Ns = ns
+	b[name+"|"+dns.TypeToString[qtype]] = resp
+}
+
+// testTree 根区和已签名的 example. 区域
+type testTree struct {
+	root, example *testZone
+	backend       fakeBackend
+}
+
+func newTestTree(t *testing.T) *testTree {
+	t.Helper()
+	tree := &testTree{
+		root:    newTestZone(t, "."),
+		example: newTestZone(t, "example."),
+		backend: make(fakeBackend),
+	}
+	tree.backend.add(".", dns.TypeDNSKEY, dns.RcodeSuccess, tree.root.sign(t, tree.root.key), nil)
+	tree.backend.add("example.", dns.TypeDNSKEY, dns.RcodeSuccess, tree.example.sign(t, tree.example.key), nil)
+	tree.backend.add("example.", dns.TypeDS, dns.RcodeSuccess, tree.root.sign(t, tree.example.key.ToDS(dns.SHA256)), nil)
+	return tree
+}
+
+func (tree *testTree) validator() *Validator {
+	v := NewValidator(tree.backend)
+	v.anchors = []*dns.DS{tree.root.key.ToDS(dns.SHA256)}
+	return v
+}
+
+func soa(zone string) *dns.SOA {
+	return &dns.SOA{
+		Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 300},
+		Ns:  "ns." + strings.TrimPrefix(zone, "."), Mbox: "hostmaster." + strings.TrimPrefix(zone, "."),
+		Serial: 1, Refresh: 3600, Retry: 600, Expire: 86400, Minttl: 300,
+	}
+}
+
+func nsec(owner, next string, types ...uint16) *dns.NSEC {
+	return &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: owner, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 300},
+		NextDomain: next,
+		TypeBitMap: types,
+	}
+}
+
+func nsec3(zone, name, next string, types ...uint16) *dns.NSEC3 {
+	return &dns.NSEC3{
+		Hdr:        dns.RR_Header{Name: dns.HashName(name, dns.SHA1, 0, "") + "." + zone, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: 300},
+		Hash:       dns.SHA1,
+		HashLength: 20,
+		NextDomain: dns.HashName(next, dns.SHA1, 0, ""),
+		TypeBitMap: types,
+	}
+}
+
+func concat(sets ...[]dns.RR) []dns.RR {
+	var out []dns.RR
+	for _, s := range sets {
+		out = append(out, s...)
+	}
+	return out
+}
+
+func TestInsecureDelegationNeedsDenialProof(t *testing.T) {
+	tests := []struct {
+		name  string
+		proof func(tree *testTree, t *testing.T) []dns.RR
+		want  string
+	}{
+		{"signed NSEC", func(tree *testTree, t *testing.T) []dns.RR {
+			return tree.root.sign(t, nsec("insecure.", ".", dns.TypeNS, dns.TypeRRSIG, dns.TypeNSEC))
+		}, types.DNSSECInsecure},
+		{"no proof", func(*testTree, *testing.T) []dns.RR {
+			return nil
+		}, types.DNSSECIndeterminate},
+		{"unsigned NSEC", func(*testTree, *testing.T) []dns.RR {
+			return []dns.RR{nsec("insecure.", ".", dns.TypeNS, dns.TypeRRSIG, dns.TypeNSEC)}
+		}, types.DNSSECIndeterminate},
+		{"NSEC with DS", func(tree *testTree, t *testing.T) []dns.RR {
+			return tree.root.sign(t, nsec("insecure.", ".", dns.TypeNS, dns.TypeDS, dns.TypeRRSIG, dns.TypeNSEC))
+		}, types.DNSSECIndeterminate},
+		{"NSEC of other name", func(tree *testTree, t *testing.T) []dns.RR {
+			return tree.root.sign(t, nsec("other.", ".", dns.TypeNS, dns.TypeRRSIG, dns.TypeNSEC))
+		}, types.DNSSECIndeterminate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := newTestTree(t)
+			tree.backend.add("www.insecure.", dns.TypeSOA, dns.RcodeSuccess, nil, []dns.RR{soa("insecure.")})
+			tree.backend.add("insecure.", dns.TypeDS, dns.RcodeSuccess, nil,
+				concat(tree.root.sign(t, soa(".")), tt.proof(tree, t)))
+
+			msg := new(dns.Msg)
+			msg.SetQuestion("www.insecure.", dns.TypeA)
+			a, _ := dns.NewRR("www.insecure. 300 IN A 192.0.2.1")
+			msg.Answer = []dns.RR{a}
+
+			status, reason := tree.validator().Validate(context.Background(), "r", msg)
+			if status != tt.want {
+				t.Errorf("status = %s, want %s (%s)", status, tt.want, reason)
+			}
+		})
+	}
+}
+
+func TestNegativeAnswerCoverage(t *testing.T) {
+	const zone = "example."
+	tests := []struct {
+		name  string
+		qname string
+		qtype uint16
+		rcode int
+		proof func(z *testZone, t *testing.T) []dns.RR
+		want  string
+	}{
+		{"NXDOMAIN covered", "nx.example.", dns.TypeA, dns.RcodeNameError, func(z *testZone, t *testing.T) []dns.RR {
+			// example. -> www.example. 同时覆盖 nx.example. 和 *.example.
+			return z.sign(t, nsec(zone, "www.example.", dns.TypeSOA, dns.TypeNS, dns.TypeRRSIG, dns.TypeNSEC, dns.TypeDNSKEY))
+		}, types.DNSSECSecure},
+		{"NXDOMAIN not covered", "nx.example.", dns.TypeA, dns.RcodeNameError, func(z *testZone, t *testing.T) []dns.RR {
+			return z.sign(t, nsec("www.example.", zone, dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC))
+		}, types.DNSSECIndeterminate},
+		{"NXDOMAIN wildcard not covered", "zz.example.", dns.TypeA, dns.RcodeNameError, func(z *testZone, t *testing.T) []dns.RR {
+			return z.sign(t, nsec("www.example.", zone, dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC))
+		}, types.DNSSECIndeterminate},
+		{"NODATA", "www.example.", dns.TypeAAAA, dns.RcodeSuccess, func(z *testZone, t *testing.T) []dns.RR {
+			return z.sign(t, nsec("www.example.", zone, dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC))
+		}, types.DNSSECSecure},
+		{"NODATA type present", "www.example.", dns.TypeAAAA, dns.RcodeSuccess, func(z *testZone, t *testing.T) []dns.RR {
+			return z.sign(t, nsec("www.example.", zone, dns.TypeA, dns.TypeAAAA, dns.TypeRRSIG, dns.TypeNSEC))
+		}, types.DNSSECIndeterminate},
+		{"NSEC3 NXDOMAIN", "nx.example.", dns.TypeA, dns.RcodeNameError, func(z *testZone, t *testing.T) []dns.RR {
+			// 两条记录组成完整的散列环，覆盖其余所有名称
+			return concat(
+				z.sign(t, nsec3(zone, zone, "www.example.", dns.TypeSOA, dns.TypeNS, dns.TypeRRSIG, dns.TypeDNSKEY, dns.TypeNSEC3PARAM)),
+				z.sign(t, nsec3(zone, "www.example.", zone, dns.TypeA, dns.TypeRRSIG)),
+			)
+		}, types.DNSSECSecure},
+		{"NSEC3 NXDOMAIN without closest encloser", "nx.example.", dns.TypeA, dns.RcodeNameError, func(z *testZone, t *testing.T) []dns.RR {
+			return z.sign(t, nsec3(zone, "www.example.", "www.example.", dns.TypeA, dns.TypeRRSIG))
+		}, types.DNSSECIndeterminate},
+		{"NSEC3 NODATA", "www.example.", dns.TypeAAAA, dns.RcodeSuccess, func(z *testZone, t *testing.T) []dns.RR {
+			return z.sign(t, nsec3(zone, "www.example.", zone, dns.TypeA, dns.TypeRRSIG))
+		}, types.DNSSECSecure},
+		{"NSEC3 NODATA type present", "www.example.", dns.TypeAAAA, dns.RcodeSuccess, func(z *testZone, t *testing.T) []dns.RR {
+			return z.sign(t, nsec3(zone, "www.example.", zone, dns.TypeA, dns.TypeAAAA, dns.TypeRRSIG))
+		}, types.DNSSECIndeterminate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := newTestTree(t)
+			msg := new(dns.Msg)
+			msg.SetQuestion(tt.qname, tt.qtype)
+			msg.Rcode = tt.rcode
+			msg.Ns = concat(tree.example.sign(t, soa(zone)), tt.proof(tree.example, t))
+
+			status, reason := tree.validator().Validate(context.Background(), "r", msg)
+			if status != tt.want {
+				t.Errorf("status = %s, want %s (%s)", status, tt.want, reason)
+			}
+		})
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/JaveleyQAQ/geodns/internal/config"
+	"github.com/JaveleyQAQ/geodns/internal/query"
 	"github.com/JaveleyQAQ/geodns/internal/types"
 )
 
@@ -16,6 +19,9 @@ type OutputFormatter struct {
 	ResponseOnly bool
 	showResponse bool
 	RecordTypes  []uint16 // 记录类型过滤
+	ShowEDNS     bool     // 是否输出各区域响应的EDNS0信息
+	ShowTTL      bool     // 是否输出各记录的TTL统计
+	ShowSections bool     // 是否输出各区域响应的头部标志、大小、权威和附加部分
 	colorful     bool     // 是否彩色输出
 }
 
@@ -64,9 +70,103 @@ func (of *OutputFormatter) FormatOutput(summary types.ResultSummary) {
 		of.outputResponseOnly(summary)
 	} else {
 		of.outputResponse(summary)
+		of.outputChains(summary)
+		of.outputSections(summary)
+		of.outputEDNS(summary)
+		of.outputECS(summary)
+		of.outputDNSSEC(summary)
+		of.outputMismatches(summary)
+		of.outputTTL(summary)
+		of.outputTrace(summary)
+		of.outputStats(summary)
 	}
 }
 
+// FormatIdentity 以区域为行、识别查询名为列输出解析器识别结果，JSON和-ro模式按普通结果输出
+func (of *OutputFormatter) FormatIdentity(summaries []types.ResultSummary) {
+	if of.jsonOutput || of.ResponseOnly {
+		for _, summary := range summaries {
+			of.FormatOutput(summary)
+		}
+		return
+	}
+
+	// 区域 -> 查询名 -> 应答（失败时为响应码或错误类别）
+	cells := make(map[string]map[string]string)
+	for _, summary := range summaries {
+		for _, result := range summary.Results {
+			if cells[result.Region] == nil {
+				cells[result.Region] = make(map[string]string)
+			}
+			var values []string
+			for _, answer := range result.Answers {
+				values = append(values, answer.Value)
+			}
+			switch {
+			case len(values) > 0:
+				cells[result.Region][summary.Domain] = strings.Join(values, " ")
+			case result.Rcode != "" && result.Rcode != "NOERROR":
+				cells[result.Region][summary.Domain] = result.Rcode
+			case result.ErrorClass != "":
+				cells[result.Region][summary.Domain] = result.ErrorClass
+			}
+		}
+	}
+	regions := make([]string, 0, len(cells))
+	for region := range cells {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	var buf strings.Builder
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	header := []string{"REGION"}
+	for _, summary := range summaries {
+		header = append(header, summary.Domain)
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, region := range regions {
+		row := []string{region}
+		for _, summary := range summaries {
+			value := cells[region][summary.Domain]
+			if value == "" {
+				value = "-"
+			}
+			row = append(row, value)
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+	of.writeOutput(buf.String())
+}
+
+// FormatTiming 输出各区域请求耗时的汇总表，JSON模式输出为 {"timing": [...]}
+func (of *OutputFormatter) FormatTiming(timings []types.RegionTiming) {
+	if of.jsonOutput {
+		jsonData, _ := json.MarshalIndent(struct {
+			Timing []types.RegionTiming `json:"timing"`
+		}{timings}, "", "  ")
+		of.writelnOutput(string(jsonData))
+		return
+	}
+
+	ms := func(v float64) string {
+		if v == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f", v)
+	}
+	var buf strings.Builder
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REGION\tREQ\tFAIL\tDNS\tCONNECT\tTLS\tTTFB\tTOTAL\tP95\tMAX")
+	for _, t := range timings {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", t.Region, t.Requests, t.Failed,
+			ms(t.DNSLookup), ms(t.Connect), ms(t.TLSHandshake), ms(t.FirstByte), ms(t.TotalMedian), ms(t.TotalP95), ms(t.TotalMax))
+	}
+	tw.Flush()
+	of.writeOutput(buf.String())
+}
+
 func (of *OutputFormatter) outputJSON(summary types.ResultSummary) {
 	jsonData, _ := json.MarshalIndent(summary, "", "  ")
 	of.writelnOutput(string(jsonData))
@@ -119,6 +219,7 @@ func (of *OutputFormatter) outputResponse(summary types.ResultSummary) {
 
 	// 重新构建有效的UniqueAnswers
 	validUniqueAnswers := make(map[string]map[string]bool)
+	geo := geoByValue(validResults)
 	for _, result := range validResults {
 		for _, answer := range result.Answers {
 			if validUniqueAnswers[answer.Type] == nil {
@@ -145,16 +246,456 @@ func (of *OutputFormatter) outputResponse(summary types.ResultSummary) {
 	for _, recordType := range recordTypes {
 		values := uniqueAnswersSlice[recordType]
 		for _, value := range values {
+			// -re 模式下标出返回该值的区域
+			regions := ""
+			if of.showResponse {
+				regions = " (" + strings.Join(summary.AnswerRegions[recordType][value], ", ") + ")"
+			}
+			location := of.geoNote(geo[value])
 			if of.colorful {
 				color := getColorForRecordType(recordType)
-				line := fmt.Sprintf("%s [%s%s%s] [%s%s%s]", summary.Domain, color, recordType, config.ColorReset, config.ColorGreen, value, config.ColorReset)
+				line := fmt.Sprintf("%s [%s%s%s] [%s%s%s]%s%s", summary.Domain, color, recordType, config.ColorReset, config.ColorGreen, value, config.ColorReset, location, regions)
 				of.writelnOutput(line)
 			} else {
-				line := fmt.Sprintf("%s [%s] [%s]", summary.Domain, recordType, value)
+				line := fmt.Sprintf("%s [%s] [%s]%s%s", summary.Domain, recordType, value, location, regions)
 				of.writelnOutput(line)
 			}
 		}
 	}
+	if of.showResponse {
+		of.outputMatrix(summary)
+		of.outputFailures(summary)
+	}
+}
+
+// geoByValue 收集结果中A/AAAA地址的地理位置信息，包括 -follow 后续查询得到的记录
+func geoByValue(results []types.RegionResult) map[string]*types.GeoInfo {
+	geo := make(map[string]*types.GeoInfo)
+	for _, result := range results {
+		for _, answers := range [][]types.DNSAnswer{result.Answers, result.Followed} {
+			for _, answer := range answers {
+				if answer.Geo != nil {
+					geo[answer.Value] = answer.Geo
+				}
+			}
+		}
+	}
+	return geo
+}
+
+// geoNote 地址后附加的地理位置标注，如 " [JP, Tokyo]"，没有信息时为空
+func (of *OutputFormatter) geoNote(info *types.GeoInfo) string {
+	if info == nil {
+		return ""
+	}
+	if of.colorful {
+		return " [" + config.ColorYellow + geoLabel(info) + config.ColorReset + "]"
+	}
+	return " [" + geoLabel(info) + "]"
+}
+
+// geoLabel 地理位置和ASN的简短描述，如 "JP, Tokyo, AS20940 Akamai International B.V."
+func geoLabel(info *types.GeoInfo) string {
+	var parts []string
+	if info.Country != "" {
+		parts = append(parts, info.Country)
+	}
+	if info.City != "" {
+		parts = append(parts, info.City)
+	}
+	if info.ASN != 0 {
+		parts = append(parts, strings.TrimSpace(fmt.Sprintf("AS%d %s", info.ASN, info.Org)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// outputMatrix 输出区域×记录集合矩阵，返回相同记录集合的区域归为一列，并列出各集合的内容
+func (of *OutputFormatter) outputMatrix(summary types.ResultSummary) {
+	sets := summary.AnswerSets
+	if len(sets) == 0 {
+		return
+	}
+
+	if len(sets) > 1 {
+		var buf strings.Builder
+		tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		header := []string{"REGION"}
+		for _, set := range sets {
+			header = append(header, set.ID)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for i, set := range sets {
+			for _, region := range set.Regions {
+				row := []string{region}
+				for j := range sets {
+					if i == j {
+						row = append(row, "x")
+					} else {
+						row = append(row, ".")
+					}
+				}
+				fmt.Fprintln(tw, strings.Join(row, "\t"))
+			}
+		}
+		tw.Flush()
+		of.writeOutput(buf.String())
+	}
+
+	for _, set := range sets {
+		answers := "(no records)"
+		if len(set.Answers) > 0 {
+			answers = strings.Join(set.Answers, ", ")
+		}
+		if set.Rcode != "" {
+			rcode := set.Rcode
+			if of.colorful {
+				rcode = config.ColorRed + rcode + config.ColorReset
+			}
+			if len(set.Answers) > 0 {
+				answers = rcode + "; " + answers
+			} else {
+				answers = rcode
+			}
+		}
+		if of.colorful {
+			of.writelnOutput(fmt.Sprintf("%s [%s%s%s] %d regions: %s", summary.Domain, config.ColorCyan, set.ID, config.ColorReset, len(set.Regions), answers))
+		} else {
+			of.writelnOutput(fmt.Sprintf("%s [%s] %d regions: %s", summary.Domain, set.ID, len(set.Regions), answers))
+		}
+	}
+}
+
+// outputFailures 按错误类别列出没有得到DNS响应的区域
+func (of *OutputFormatter) outputFailures(summary types.ResultSummary) {
+	for _, set := range summary.Failures {
+		regions := strings.Join(set.Regions, ", ")
+		if of.colorful {
+			of.writelnOutput(fmt.Sprintf("%s [%sfailed: %s%s] %d regions: %s", summary.Domain, config.ColorRed, set.Class, config.ColorReset, len(set.Regions), regions))
+		} else {
+			of.writelnOutput(fmt.Sprintf("%s [failed: %s] %d regions: %s", summary.Domain, set.Class, len(set.Regions), regions))
+		}
+	}
+}
+
+// outputChains 输出各区域重建的CNAME链，相同的链合并并列出返回该链的区域
+func (of *OutputFormatter) outputChains(summary types.ResultSummary) {
+	type group struct {
+		chain   *types.CNAMEChain
+		regions []string
+	}
+	var groups []*group
+	byKey := make(map[string]*group)
+	valid := 0
+	geo := geoByValue(summary.Results)
+	for _, result := range summary.Results {
+		if result.Error == "" {
+			valid++
+		}
+		chain := result.Chain
+		if chain == nil || !of.shouldIncludeRecordType(chain.Type) {
+			continue
+		}
+		final := append([]string(nil), chain.Final...)
+		sort.Strings(final)
+		key := fmt.Sprintf("%s|%s|%s|%t|%t|%d|%s|%s", strings.ToLower(strings.Join(chain.Names, " ")), chain.Type, strings.Join(final, " "), chain.Loop, chain.TooLong, chain.Followed, chain.Rcode, chain.Error)
+		g := byKey[key]
+		if g == nil {
+			g = &group{chain: &types.CNAMEChain{
+				Names:    chain.Names,
+				Type:     chain.Type,
+				Final:    final,
+				Followed: chain.Followed,
+				Loop:     chain.Loop,
+				TooLong:  chain.TooLong,
+				Rcode:    chain.Rcode,
+				Error:    chain.Error,
+			}}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.regions = append(g.regions, result.Region)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return len(groups[i].regions) > len(groups[j].regions) })
+
+	for _, g := range groups {
+		names := make([]string, len(g.chain.Names))
+		for i, name := range g.chain.Names {
+			names[i] = strings.TrimSuffix(name, ".")
+		}
+		final := "-"
+		if len(g.chain.Final) > 0 {
+			values := make([]string, len(g.chain.Final))
+			for i, value := range g.chain.Final {
+				if of.colorful {
+					value = config.ColorGreen + value + config.ColorReset
+				}
+				values[i] = value + of.geoNote(geo[g.chain.Final[i]])
+			}
+			final = strings.Join(values, ", ")
+		}
+		sort.Strings(g.regions)
+		regions := strings.Join(g.regions, ", ")
+		if len(g.regions) == valid && valid > 1 {
+			regions = fmt.Sprintf("all %d regions", valid)
+		}
+
+		var notes []string
+		if g.chain.Loop {
+			notes = append(notes, "LOOP")
+		}
+		if g.chain.TooLong {
+			notes = append(notes, "TOO LONG")
+		}
+		if g.chain.Followed > 0 {
+			notes = append(notes, fmt.Sprintf("followed %d", g.chain.Followed))
+		}
+		if g.chain.Rcode != "" {
+			notes = append(notes, "end "+g.chain.Rcode)
+		}
+		if g.chain.Error != "" {
+			notes = append(notes, "end failed: "+g.chain.Error)
+		}
+		note := ""
+		if len(notes) > 0 {
+			note = " [" + strings.Join(notes, ", ") + "]"
+		}
+
+		if of.colorful {
+			if g.chain.Loop || g.chain.TooLong || g.chain.Rcode != "" || g.chain.Error != "" {
+				note = config.ColorRed + note + config.ColorReset
+			}
+			color := getColorForRecordType(g.chain.Type)
+			of.writelnOutput(fmt.Sprintf("%s [%s%s%s] %s%s (%s)", strings.Join(names, " → "), color, g.chain.Type, config.ColorReset, final, note, regions))
+		} else {
+			of.writelnOutput(fmt.Sprintf("%s [%s] %s%s (%s)", strings.Join(names, " → "), g.chain.Type, final, note, regions))
+		}
+	}
+}
+
+// outputMismatches 输出响应与查询不一致（可能被篡改）的区域
+func (of *OutputFormatter) outputMismatches(summary types.ResultSummary) {
+	for _, result := range summary.Results {
+		if len(result.Mismatches) == 0 {
+			continue
+		}
+		detail := strings.Join(result.Mismatches, "; ")
+		if of.colorful {
+			of.writelnOutput(fmt.Sprintf("%s [%sMISMATCH%s] [%s] %s", summary.Domain, config.ColorRed, config.ColorReset, result.Region, detail))
+		} else {
+			of.writelnOutput(fmt.Sprintf("%s [MISMATCH] [%s] %s", summary.Domain, result.Region, detail))
+		}
+	}
+}
+
+// outputTTL 输出各记录的TTL统计和估计的缓存时长，并标出TTL高于权威值的区域
+func (of *OutputFormatter) outputTTL(summary types.ResultSummary) {
+	if !of.ShowTTL {
+		return
+	}
+	for _, stats := range summary.TTLs {
+		if !of.shouldIncludeRecordType(stats.Type) {
+			continue
+		}
+		detail := fmt.Sprintf("min=%d median=%d max=%d original=%d (%s) cache_age=%d-%ds regions=%d",
+			stats.Min, stats.Median, stats.Max, stats.Original, stats.OriginalSource, stats.MinCacheAge, stats.MaxCacheAge, stats.Regions)
+		if of.colorful {
+			of.writelnOutput(fmt.Sprintf("%s [%sTTL%s] [%s] [%s] %s", summary.Domain, config.ColorCyan, config.ColorReset, stats.Type, stats.Value, detail))
+			if len(stats.AboveOriginal) > 0 {
+				of.writelnOutput(fmt.Sprintf("%s [%sTTL%s] [%s] [%s] %sabove original in: %s%s", summary.Domain, config.ColorCyan, config.ColorReset, stats.Type, stats.Value, config.ColorRed, strings.Join(stats.AboveOriginal, ", "), config.ColorReset))
+			}
+		} else {
+			of.writelnOutput(fmt.Sprintf("%s [TTL] [%s] [%s] %s", summary.Domain, stats.Type, stats.Value, detail))
+			if len(stats.AboveOriginal) > 0 {
+				of.writelnOutput(fmt.Sprintf("%s [TTL] [%s] [%s] above original in: %s", summary.Domain, stats.Type, stats.Value, strings.Join(stats.AboveOriginal, ", ")))
+			}
+		}
+	}
+}
+
+// outputStats 存在失败或不一致区域时输出区域结果统计，如 "45/48 ok, 3 timeout"
+func (of *OutputFormatter) outputStats(summary types.ResultSummary) {
+	stats := summary.Stats
+	if stats.Total == stats.Succeeded && stats.Mismatched == 0 {
+		return
+	}
+
+	classes := make([]string, 0, len(stats.Errors))
+	for class := range stats.Errors {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	parts := []string{fmt.Sprintf("%d/%d ok", stats.Succeeded, stats.Total)}
+	for _, class := range classes {
+		parts = append(parts, fmt.Sprintf("%d %s", stats.Errors[class], class))
+	}
+	if stats.Mismatched > 0 {
+		parts = append(parts, fmt.Sprintf("%d mismatched", stats.Mismatched))
+	}
+
+	if of.colorful {
+		of.writelnOutput(fmt.Sprintf("%s [%sSTATS%s] %s", summary.Domain, config.ColorYellow, config.ColorReset, strings.Join(parts, ", ")))
+	} else {
+		of.writelnOutput(fmt.Sprintf("%s [STATS] %s", summary.Domain, strings.Join(parts, ", ")))
+	}
+}
+
+// outputEDNS 输出各区域响应中的EDNS0信息（版本、缓冲区大小、DO位、NSID、服务器cookie）
+func (of *OutputFormatter) outputEDNS(summary types.ResultSummary) {
+	if !of.ShowEDNS {
+		return
+	}
+	for _, result := range summary.Results {
+		if result.Rcode == "" {
+			continue // 没有收到DNS响应
+		}
+
+		var detail string
+		if edns := result.EDNS; edns == nil {
+			detail = "no OPT"
+		} else {
+			parts := []string{fmt.Sprintf("v%d", edns.Version), fmt.Sprintf("udp=%d", edns.UDPSize)}
+			if edns.DO {
+				parts = append(parts, "do")
+			}
+			if edns.NSID != "" {
+				parts = append(parts, "nsid="+edns.NSID)
+			}
+			if edns.ServerCookie != "" {
+				parts = append(parts, "cookie="+edns.ServerCookie)
+			}
+			detail = strings.Join(parts, " ")
+		}
+
+		if of.colorful {
+			of.writelnOutput(fmt.Sprintf("%s [%sEDNS%s] [%s] %s", summary.Domain, config.ColorCyan, config.ColorReset, result.Region, detail))
+		} else {
+			of.writelnOutput(fmt.Sprintf("%s [EDNS] [%s] %s", summary.Domain, result.Region, detail))
+		}
+	}
+}
+
+// outputECS 输出各区域响应中返回的ECS子网和scope，scope为0表示结果不随客户端子网变化
+func (of *OutputFormatter) outputECS(summary types.ResultSummary) {
+	for _, result := range summary.Results {
+		if result.ECS == nil {
+			continue
+		}
+		detail := fmt.Sprintf("%s scope /%d", result.ECS.Subnet, result.ECS.Scope)
+		if of.colorful {
+			of.writelnOutput(fmt.Sprintf("%s [%sECS%s] [%s] %s", summary.Domain, config.ColorCyan, config.ColorReset, result.Region, detail))
+		} else {
+			of.writelnOutput(fmt.Sprintf("%s [ECS] [%s] %s", summary.Domain, result.Region, detail))
+		}
+	}
+}
+
+// outputSections 输出各区域响应的头部标志和大小，以及权威部分和附加部分的记录
+func (of *OutputFormatter) outputSections(summary types.ResultSummary) {
+	if !of.ShowSections {
+		return
+	}
+	for _, result := range summary.Results {
+		if result.Flags == nil {
+			continue // 没有收到DNS响应
+		}
+
+		parts := []string{result.Rcode}
+		for _, flag := range []struct {
+			name string
+			set  bool
+		}{
+			{"aa", result.Flags.AA},
+			{"tc", result.Flags.TC},
+			{"ra", result.Flags.RA},
+			{"ad", result.Flags.AD},
+			{"cd", result.Flags.CD},
+		} {
+			if flag.set {
+				parts = append(parts, flag.name)
+			}
+		}
+		parts = append(parts, fmt.Sprintf("size=%d", result.Size),
+			fmt.Sprintf("an=%d ns=%d ar=%d", len(result.Answers), len(result.Authority), len(result.Additional)))
+		of.writeSection(summary.Domain, "HEADER", result.Region, strings.Join(parts, " "))
+
+		for _, rr := range result.Authority {
+			of.writeSection(summary.Domain, "AUTHORITY", result.Region, fmt.Sprintf("%s %d %s %s", rr.Name, rr.TTL, rr.Type, rr.Value))
+		}
+		for _, rr := range result.Additional {
+			of.writeSection(summary.Domain, "ADDITIONAL", result.Region, fmt.Sprintf("%s %d %s %s", rr.Name, rr.TTL, rr.Type, rr.Value))
+		}
+	}
+}
+
+// writeSection 输出一行带标签的区域信息
+func (of *OutputFormatter) writeSection(domain, tag, region, detail string) {
+	if of.colorful {
+		of.writelnOutput(fmt.Sprintf("%s [%s%s%s] [%s] %s", domain, config.ColorCyan, tag, config.ColorReset, region, detail))
+	} else {
+		of.writelnOutput(fmt.Sprintf("%s [%s] [%s] %s", domain, tag, region, detail))
+	}
+}
+
+// outputDNSSEC 输出各区域的DNSSEC验证结果和上游的AD位（仅验证模式的结果带有）
+func (of *OutputFormatter) outputDNSSEC(summary types.ResultSummary) {
+	for _, result := range summary.Results {
+		info := result.DNSSEC
+		if info == nil {
+			continue
+		}
+
+		detail := info.Status
+		if info.AD {
+			detail += " ad"
+		}
+		if info.CD {
+			detail += " cd"
+		}
+		if info.Reason != "" {
+			detail += ": " + info.Reason
+		}
+
+		if of.colorful {
+			color := config.ColorGreen
+			switch info.Status {
+			case types.DNSSECBogus:
+				color = config.ColorRed
+			case types.DNSSECInsecure, types.DNSSECIndeterminate:
+				color = config.ColorYellow
+			}
+			of.writelnOutput(fmt.Sprintf("%s [%sDNSSEC%s] [%s] %s%s%s", summary.Domain, config.ColorCyan, config.ColorReset, result.Region, color, detail, config.ColorReset))
+		} else {
+			of.writelnOutput(fmt.Sprintf("%s [DNSSEC] [%s] %s", summary.Domain, result.Region, detail))
+		}
+	}
+}
+
+// outputTrace 输出迭代解析的每一跳（仅迭代模式的结果带有查询路径）
+func (of *OutputFormatter) outputTrace(summary types.ResultSummary) {
+	for _, result := range summary.Results {
+		for _, hop := range result.Trace {
+			var detail string
+			switch {
+			case hop.Error != "":
+				detail = "error: " + hop.Error
+			case hop.ReferralZone != "":
+				detail = fmt.Sprintf("-> %s [%s] (%d glue)", hop.ReferralZone, strings.Join(hop.Referral, " "), len(hop.Glue))
+			default:
+				detail = hop.Rcode
+				if hop.Authoritative {
+					detail += " aa"
+				}
+				detail += fmt.Sprintf(" (%d answers)", len(hop.Answers))
+			}
+
+			if of.colorful {
+				of.writelnOutput(fmt.Sprintf("%s [%sTRACE%s] [%s] %s @%s %s", summary.Domain, config.ColorCyan, config.ColorReset, result.Region, hop.Zone, hop.Server, detail))
+			} else {
+				of.writelnOutput(fmt.Sprintf("%s [TRACE] [%s] %s @%s %s", summary.Domain, result.Region, hop.Zone, hop.Server, detail))
+			}
+		}
+	}
 }
 
 func (of *OutputFormatter) shouldIncludeRecordType(recordType string) bool {
@@ -169,31 +710,9 @@ func (of *OutputFormatter) shouldIncludeRecordType(recordType string) bool {
 	return false
 }
 
+// GetRecordTypeName 获取记录类型名称
 func (of *OutputFormatter) GetRecordTypeName(recordType uint16) string {
-	switch recordType {
-	case 1:
-		return "A"
-	case 28:
-		return "AAAA"
-	case 5:
-		return "CNAME"
-	case 2:
-		return "NS"
-	case 16:
-		return "TXT"
-	case 33:
-		return "SRV"
-	case 12:
-		return "PTR"
-	case 15:
-		return "MX"
-	case 6:
-		return "SOA"
-	case 257:
-		return "CAA"
-	default:
-		return "UNKNOWN"
-	}
+	return query.TypeName(recordType)
 }
 
 func getColorForRecordType(recordType string) string {
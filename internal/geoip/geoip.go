@@ -0,0 +1,86 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// record MaxMind GeoLite2/GeoIP2 与 DB-IP 的City、Country、ASN数据库共用的字段
+type record struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+	ASN uint   `maxminddb:"autonomous_system_number"`
+	Org string `maxminddb:"autonomous_system_organization"`
+}
+
+// DB 一组本地MMDB数据库，查询时依次合并各库的结果
+type DB struct {
+	readers []*maxminddb.Reader
+}
+
+// Open 打开MMDB文件，City/Country库和ASN库可同时使用
+func Open(paths []string) (*DB, error) {
+	db := &DB{}
+	for _, path := range paths {
+		reader, err := maxminddb.Open(path)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("opening %s: %w", path, err)
+		}
+		db.readers = append(db.readers, reader)
+	}
+	return db, nil
+}
+
+// Lookup 查询IP的地理位置和ASN信息，所有库都没有记录时返回nil
+func (db *DB) Lookup(ip net.IP) *types.GeoInfo {
+	info := &types.GeoInfo{}
+	for _, reader := range db.readers {
+		var r record
+		if err := reader.Lookup(ip, &r); err != nil {
+			continue
+		}
+		if info.Country == "" {
+			info.Country = r.Country.ISOCode
+			info.CountryName = r.Country.Names["en"]
+		}
+		if info.City == "" {
+			info.City = r.City.Names["en"]
+		}
+		if info.Latitude == nil && r.Location.Latitude != nil {
+			info.Latitude = r.Location.Latitude
+			info.Longitude = r.Location.Longitude
+		}
+		if info.ASN == 0 {
+			info.ASN = r.ASN
+			info.Org = r.Org
+		}
+	}
+	if *info == (types.GeoInfo{}) {
+		return nil
+	}
+	return info
+}
+
+// Close 关闭所有数据库
+func (db *DB) Close() error {
+	var firstErr error
+	for _, reader := range db.readers {
+		if err := reader.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,172 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mmdbNode 构建MMDB搜索树时的节点，leaf为true时data为数据区中的偏移
+type mmdbNode struct {
+	children [2]*mmdbNode
+	leaf     bool
+	data     int
+}
+
+// writeMMDB 生成只包含IPv4网段的MMDB文件（24位记录），networks为CIDR到记录的映射
+func writeMMDB(t *testing.T, dbType string, networks map[string]map[string]interface{}) string {
+	t.Helper()
+	var data bytes.Buffer
+	root := &mmdbNode{}
+	for cidr, record := range networks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := network.Mask.Size()
+		node := root
+		for i := 0; i < ones; i++ {
+			bit := network.IP.To4()[i/8] >> (7 - i%8) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &mmdbNode{}
+			}
+			node = node.children[bit]
+		}
+		node.leaf = true
+		node.data = data.Len()
+		encodeMMDB(&data, record)
+	}
+
+	// 按广度优先为内部节点编号，根节点为0
+	nodes := []*mmdbNode{root}
+	index := map[*mmdbNode]int{root: 0}
+	for i := 0; i < len(nodes); i++ {
+		for _, child := range nodes[i].children {
+			if child != nil && !child.leaf {
+				index[child] = len(nodes)
+				nodes = append(nodes, child)
+			}
+		}
+	}
+	var file bytes.Buffer
+	for _, node := range nodes {
+		for _, child := range node.children {
+			value := len(nodes) // 没有记录
+			switch {
+			case child != nil && child.leaf:
+				value = len(nodes) + 16 + child.data
+			case child != nil:
+				value = index[child]
+			}
+			file.Write([]byte{byte(value >> 16), byte(value >> 8), byte(value)})
+		}
+	}
+	file.Write(make([]byte, 16))
+	file.Write(data.Bytes())
+	file.WriteString("\xAB\xCD\xEFMaxMind.com")
+	encodeMMDB(&file, map[string]interface{}{
+		"node_count":                  uint32(len(nodes)),
+		"record_size":                 uint16(24),
+		"ip_version":                  uint16(4),
+		"database_type":               dbType,
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+	})
+
+	path := filepath.Join(t.TempDir(), dbType+".mmdb")
+	if err := os.WriteFile(path, file.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// encodeMMDB 按MMDB数据格式编码map、字符串、double、uint16和uint32
+func encodeMMDB(buf *bytes.Buffer, value interface{}) {
+	control := func(typ byte, size int) {
+		switch {
+		case size < 29:
+			buf.WriteByte(typ<<5 | byte(size))
+		default:
+			buf.WriteByte(typ<<5 | 29)
+			buf.WriteByte(byte(size - 29))
+		}
+	}
+	switch v := value.(type) {
+	case string:
+		control(2, len(v))
+		buf.WriteString(v)
+	case float64:
+		control(3, 8)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case uint16:
+		control(5, 2)
+		binary.Write(buf, binary.BigEndian, v)
+	case uint32:
+		control(6, 4)
+		binary.Write(buf, binary.BigEndian, v)
+	case map[string]interface{}:
+		control(7, len(v))
+		for key, item := range v {
+			encodeMMDB(buf, key)
+			encodeMMDB(buf, item)
+		}
+	default:
+		panic("unsupported mmdb value")
+	}
+}
+
+func TestLookupMergesDatabases(t *testing.T) {
+	city := writeMMDB(t, "GeoLite2-City", map[string]map[string]interface{}{
+		"192.0.2.0/24": {
+			"country": map[string]interface{}{
+				"iso_code": "JP",
+				"names":    map[string]interface{}{"en": "Japan"},
+			},
+			"city":     map[string]interface{}{"names": map[string]interface{}{"en": "Tokyo"}},
+			"location": map[string]interface{}{"latitude": 35.69, "longitude": 139.69},
+		},
+	})
+	asn := writeMMDB(t, "GeoLite2-ASN", map[string]map[string]interface{}{
+		"192.0.2.0/24": {
+			"autonomous_system_number":       uint32(64500),
+			"autonomous_system_organization": "Example Networks",
+		},
+		"198.51.100.0/25": {
+			"autonomous_system_number":       uint32(64501),
+			"autonomous_system_organization": "Other Networks",
+		},
+	})
+
+	db, err := Open([]string{city, asn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	info := db.Lookup(net.ParseIP("192.0.2.10"))
+	if info == nil {
+		t.Fatal("192.0.2.10: got nil, want merged city and ASN info")
+	}
+	if info.Country != "JP" || info.CountryName != "Japan" || info.City != "Tokyo" || info.ASN != 64500 || info.Org != "Example Networks" {
+		t.Errorf("192.0.2.10 = %+v", info)
+	}
+	if info.Latitude == nil || *info.Latitude != 35.69 || info.Longitude == nil || *info.Longitude != 139.69 {
+		t.Errorf("192.0.2.10 location = %v, %v", info.Latitude, info.Longitude)
+	}
+
+	// 只有ASN库有记录的地址只带ASN信息
+	info = db.Lookup(net.ParseIP("198.51.100.1"))
+	if info == nil || info.ASN != 64501 || info.Country != "" || info.Latitude != nil {
+		t.Errorf("198.51.100.1 = %+v, want ASN only", info)
+	}
+
+	for _, ip := range []string{"198.51.100.200", "203.0.113.1", "2001:db8::1"} {
+		if info := db.Lookup(net.ParseIP(ip)); info != nil {
+			t.Errorf("%s = %+v, want nil", ip, info)
+		}
+	}
+}
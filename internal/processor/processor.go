@@ -1,17 +1,31 @@
 package processor
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/JaveleyQAQ/geodns/internal/types"
 )
 
 // DNSProcessor DNS结果处理器
 type DNSProcessor struct {
-	results []types.RegionResult
+	results       []types.RegionResult
+	authoritative map[string][]types.DNSAnswer // 域名 -> 权威服务器的应答，用于TTL分析
 }
 
 // Reset 清空历史结果
 func (dp *DNSProcessor) Reset() {
 	dp.results = make([]types.RegionResult, 0)
+	dp.authoritative = nil
+}
+
+// SetAuthoritative 记录域名在权威服务器上的应答，作为TTL分析的原始TTL
+func (dp *DNSProcessor) SetAuthoritative(domain string, answers []types.DNSAnswer) {
+	if dp.authoritative == nil {
+		dp.authoritative = make(map[string][]types.DNSAnswer)
+	}
+	dp.authoritative[domain] = append(dp.authoritative[domain], answers...)
 }
 
 // NewDNSProcessor 创建新的DNS处理器
@@ -31,15 +45,38 @@ func (dp *DNSProcessor) ProcessResults(resultChan <-chan types.RegionResult) {
 // GetSummary 获取结果汇总
 func (dp *DNSProcessor) GetSummary(domain string) types.ResultSummary {
 	uniqueAnswers := make(map[string]map[string]bool)
+	answerRegions := make(map[string]map[string]map[string]bool) // 类型 -> 值 -> 区域
+	results := make([]types.RegionResult, 0)
+	stats := types.ResultStats{Errors: make(map[string]int)}
 
 	for _, res := range dp.results {
 		// 只处理指定域名的结果
 		if res.Domain == domain {
+			results = append(results, res)
+			stats.Total++
+			if len(res.Mismatches) > 0 {
+				stats.Mismatched++
+			}
+			switch {
+			case res.Error == "":
+				stats.Succeeded++
+			case res.ErrorClass == types.ErrorRcode:
+				stats.Errors[res.Rcode]++
+			default:
+				stats.Errors[res.ErrorClass]++
+			}
 			for _, answer := range res.Answers {
 				if uniqueAnswers[answer.Type] == nil {
 					uniqueAnswers[answer.Type] = make(map[string]bool)
 				}
 				uniqueAnswers[answer.Type][answer.Value] = true
+				if answerRegions[answer.Type] == nil {
+					answerRegions[answer.Type] = make(map[string]map[string]bool)
+				}
+				if answerRegions[answer.Type][answer.Value] == nil {
+					answerRegions[answer.Type][answer.Value] = make(map[string]bool)
+				}
+				answerRegions[answer.Type][answer.Value][res.Region] = true
 			}
 		}
 	}
@@ -50,9 +87,256 @@ func (dp *DNSProcessor) GetSummary(domain string) types.ResultSummary {
 		uniqueAnswersSlice[recordType] = types.Keys(values)
 	}
 
+	answerRegionsSlice := make(map[string]map[string][]string)
+	for recordType, values := range answerRegions {
+		answerRegionsSlice[recordType] = make(map[string][]string)
+		for value, regions := range values {
+			answerRegionsSlice[recordType][value] = types.Keys(regions)
+		}
+	}
+
 	return types.ResultSummary{
 		Domain:        domain,
-		Results:       dp.results,
+		Results:       results,
 		UniqueAnswers: uniqueAnswersSlice,
+		AnswerRegions: answerRegionsSlice,
+		AnswerSets:    answerSets(results),
+		Failures:      failureSets(results),
+		Stats:         stats,
+		TTLs:          dp.ttlStats(domain, results),
+	}
+}
+
+// answerSets 按返回的记录集合对得到DNS响应的区域分组，同一区域的多个查询类型合并计算；
+// 返回错误响应码的区域以响应码区分，没有得到响应的区域见 failureSets。按区域数降序排列
+func answerSets(results []types.RegionResult) []types.AnswerSet {
+	records := make(map[string]map[string]bool) // 区域 -> "类型 值"
+	rcodes := make(map[string]map[string]bool)  // 区域 -> 非NOERROR的响应码
+	failed := make(map[string]bool)
+	for _, res := range results {
+		if res.Error != "" && res.ErrorClass != types.ErrorRcode {
+			failed[res.Region] = true
+			continue
+		}
+		if records[res.Region] == nil {
+			records[res.Region] = make(map[string]bool)
+			rcodes[res.Region] = make(map[string]bool)
+		}
+		if res.ErrorClass == types.ErrorRcode {
+			rcodes[res.Region][res.Rcode] = true
+		}
+		for _, answer := range res.Answers {
+			records[res.Region][answer.Type+" "+answer.Value] = true
+		}
+	}
+
+	var sets []types.AnswerSet
+	byKey := make(map[string]int)
+	regions := make([]string, 0, len(records))
+	for region := range records {
+		// 部分查询类型失败的区域记录集合不完整，只在failureSets中报告
+		if !failed[region] {
+			regions = append(regions, region)
+		}
+	}
+	sort.Strings(regions)
+	for _, region := range regions {
+		answers := types.Keys(records[region])
+		rcode := strings.Join(types.Keys(rcodes[region]), ",")
+		key := rcode + "\n" + strings.Join(answers, "\n")
+		i, ok := byKey[key]
+		if !ok {
+			i = len(sets)
+			byKey[key] = i
+			sets = append(sets, types.AnswerSet{Rcode: rcode, Answers: answers})
+		}
+		sets[i].Regions = append(sets[i].Regions, region)
+	}
+	sort.SliceStable(sets, func(i, j int) bool { return len(sets[i].Regions) > len(sets[j].Regions) })
+	for i := range sets {
+		sets[i].ID = fmt.Sprintf("S%d", i+1)
+	}
+	return sets
+}
+
+// failureSets 按错误类别对没有得到DNS响应（超时、连接失败、HTTP错误、跳过等）的区域分组，按区域数降序排列
+func failureSets(results []types.RegionResult) []types.FailureSet {
+	classes := make(map[string]map[string]bool) // 区域 -> 错误类别
+	failed := make(map[string]bool)
+	for _, res := range results {
+		if res.Error == "" || res.ErrorClass == types.ErrorRcode {
+			continue
+		}
+		if classes[res.Region] == nil {
+			classes[res.Region] = make(map[string]bool)
+			failed[res.Region] = true
+		}
+		classes[res.Region][res.ErrorClass] = true
+	}
+
+	var sets []types.FailureSet
+	byClass := make(map[string]int)
+	for _, region := range types.Keys(failed) {
+		class := strings.Join(types.Keys(classes[region]), ",")
+		i, ok := byClass[class]
+		if !ok {
+			i = len(sets)
+			byClass[class] = i
+			sets = append(sets, types.FailureSet{Class: class})
+		}
+		sets[i].Regions = append(sets[i].Regions, region)
+	}
+	sort.SliceStable(sets, func(i, j int) bool { return len(sets[i].Regions) > len(sets[j].Regions) })
+	return sets
+}
+
+// ttlStats 按记录统计各区域返回的TTL，并与权威TTL（没有时取观察到的最大值）比较估计缓存时长
+func (dp *DNSProcessor) ttlStats(domain string, results []types.RegionResult) []types.TTLStats {
+	type record struct {
+		rtype string
+		value string
+	}
+	type observation struct {
+		region string
+		ttl    uint32
+	}
+	observed := make(map[record][]observation)
+	var records []record
+	for _, res := range results {
+		if res.Error != "" {
+			continue
+		}
+		for _, answer := range res.Answers {
+			rec := record{answer.Type, answer.Value}
+			if _, ok := observed[rec]; !ok {
+				records = append(records, rec)
+			}
+			observed[rec] = append(observed[rec], observation{res.Region, answer.TTL})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].rtype != records[j].rtype {
+			return records[i].rtype < records[j].rtype
+		}
+		return records[i].value < records[j].value
+	})
+
+	authTTL := make(map[record]uint32)
+	for _, answer := range dp.authoritative[domain] {
+		authTTL[record{answer.Type, answer.Value}] = answer.TTL
+	}
+
+	stats := make([]types.TTLStats, 0, len(records))
+	for _, rec := range records {
+		obs := observed[rec]
+		sort.Slice(obs, func(i, j int) bool { return obs[i].ttl < obs[j].ttl })
+
+		s := types.TTLStats{
+			Type:    rec.rtype,
+			Value:   rec.value,
+			Regions: len(obs),
+			Min:     obs[0].ttl,
+			Max:     obs[len(obs)-1].ttl,
+		}
+		if mid := len(obs) / 2; len(obs)%2 == 1 {
+			s.Median = obs[mid].ttl
+		} else {
+			s.Median = (obs[mid-1].ttl + obs[mid].ttl) / 2
+		}
+
+		if ttl, ok := authTTL[rec]; ok {
+			s.Original = ttl
+			s.OriginalSource = types.TTLSourceAuthoritative
+		} else {
+			s.Original = s.Max
+			s.OriginalSource = types.TTLSourceMaxObserved
+		}
+		if s.Original > s.Max {
+			s.MinCacheAge = s.Original - s.Max
+		}
+		if s.Original > s.Min {
+			s.MaxCacheAge = s.Original - s.Min
+		}
+		for _, o := range obs {
+			if o.ttl > s.Original {
+				s.AboveOriginal = append(s.AboveOriginal, o.region)
+			}
+		}
+		sort.Strings(s.AboveOriginal)
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// TimingSummary 按区域汇总所有结果的请求耗时，按总耗时中位数升序排列
+func (dp *DNSProcessor) TimingSummary() []types.RegionTiming {
+	type samples struct {
+		failed                                    int
+		dnsLookup, connect, tls, firstByte, total []float64
+	}
+	byRegion := make(map[string]*samples)
+	for _, res := range dp.results {
+		if res.Timing == nil {
+			continue // 熔断或预算用完而未发出请求
+		}
+		s := byRegion[res.Region]
+		if s == nil {
+			s = &samples{}
+			byRegion[res.Region] = s
+		}
+		if res.Error != "" && res.ErrorClass != types.ErrorRcode {
+			s.failed++
+		}
+		t := res.Timing
+		// 连接阶段只统计新建连接的请求
+		if t.DNSLookup > 0 {
+			s.dnsLookup = append(s.dnsLookup, t.DNSLookup)
+		}
+		if t.Connect > 0 {
+			s.connect = append(s.connect, t.Connect)
+		}
+		if t.TLSHandshake > 0 {
+			s.tls = append(s.tls, t.TLSHandshake)
+		}
+		if t.FirstByte > 0 {
+			s.firstByte = append(s.firstByte, t.FirstByte)
+		}
+		s.total = append(s.total, t.Total)
+	}
+
+	summary := make([]types.RegionTiming, 0, len(byRegion))
+	for region, s := range byRegion {
+		summary = append(summary, types.RegionTiming{
+			Region:       region,
+			Requests:     len(s.total),
+			Failed:       s.failed,
+			DNSLookup:    percentile(s.dnsLookup, 50),
+			Connect:      percentile(s.connect, 50),
+			TLSHandshake: percentile(s.tls, 50),
+			FirstByte:    percentile(s.firstByte, 50),
+			TotalMedian:  percentile(s.total, 50),
+			TotalP95:     percentile(s.total, 95),
+			TotalMax:     percentile(s.total, 100),
+		})
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].TotalMedian != summary[j].TotalMedian {
+			return summary[i].TotalMedian < summary[j].TotalMedian
+		}
+		return summary[i].Region < summary[j].Region
+	})
+	return summary
+}
+
+// percentile 返回样本的p分位数（最近秩法），没有样本时返回0
+func percentile(values []float64, p int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	rank := (p*len(values) + 99) / 100
+	if rank < 1 {
+		rank = 1
 	}
+	return values[rank-1]
 }
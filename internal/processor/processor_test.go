@@ -0,0 +1,158 @@
+package processor
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/JaveleyQAQ/geodns/internal/types"
+)
+
+// runResults 把结果依次交给处理器
+func runResults(dp *DNSProcessor, results ...types.RegionResult) {
+	ch := make(chan types.RegionResult, len(results))
+	for _, res := range results {
+		ch <- res
+	}
+	close(ch)
+	dp.ProcessResults(ch)
+}
+
+func TestSummaryStats(t *testing.T) {
+	dp := NewDNSProcessor()
+	runResults(dp,
+		types.RegionResult{Domain: "a.com", Region: "r1"},
+		types.RegionResult{Domain: "a.com", Region: "r2", Mismatches: []string{"id 1 != 2"}},
+		types.RegionResult{Domain: "a.com", Region: "r3", ErrorClass: types.ErrorRcode, Rcode: "NXDOMAIN", Error: "DNS NXDOMAIN"},
+		types.RegionResult{Domain: "a.com", Region: "r4", ErrorClass: types.ErrorRcode, Rcode: "SERVFAIL", Error: "DNS SERVFAIL"},
+		types.RegionResult{Domain: "a.com", Region: "r5", ErrorClass: types.ErrorTimeout, Error: "timeout"},
+		types.RegionResult{Domain: "a.com", Region: "r6", ErrorClass: types.ErrorTimeout, Error: "timeout"},
+		types.RegionResult{Domain: "a.com", Region: "r7", ErrorClass: types.ErrorHTTPStatus, HTTPStatus: 429, Error: "429"},
+		types.RegionResult{Domain: "b.com", Region: "r1", ErrorClass: types.ErrorConnect, Error: "refused"},
+	)
+
+	stats := dp.GetSummary("a.com").Stats
+	if stats.Total != 7 || stats.Succeeded != 2 || stats.Mismatched != 1 {
+		t.Errorf("total = %d, succeeded = %d, mismatched = %d, want 7, 2, 1", stats.Total, stats.Succeeded, stats.Mismatched)
+	}
+	want := map[string]int{"NXDOMAIN": 1, "SERVFAIL": 1, types.ErrorTimeout: 2, types.ErrorHTTPStatus: 1}
+	if len(stats.Errors) != len(want) {
+		t.Errorf("errors = %v, want %v", stats.Errors, want)
+	}
+	for key, n := range want {
+		if stats.Errors[key] != n {
+			t.Errorf("errors[%s] = %d, want %d", key, stats.Errors[key], n)
+		}
+	}
+
+	other := dp.GetSummary("b.com").Stats
+	if other.Total != 1 || other.Succeeded != 0 || other.Errors[types.ErrorConnect] != 1 {
+		t.Errorf("b.com stats = %+v, want one connect error", other)
+	}
+}
+
+// aResult 返回一条A记录的成功结果
+func aResult(region, ip string, ttl uint32) types.RegionResult {
+	return types.RegionResult{
+		Domain:  "a.com",
+		Region:  region,
+		Answers: []types.DNSAnswer{{Name: "a.com.", Type: "A", TTL: ttl, Value: ip}},
+	}
+}
+
+func TestTTLStats(t *testing.T) {
+	dp := NewDNSProcessor()
+	runResults(dp,
+		aResult("r1", "192.0.2.1", 300),
+		aResult("r2", "192.0.2.1", 100),
+		aResult("r3", "192.0.2.1", 250),
+		aResult("r4", "192.0.2.1", 40),
+		aResult("r5", "192.0.2.2", 60),
+		types.RegionResult{Domain: "a.com", Region: "r6", ErrorClass: types.ErrorTimeout, Error: "timeout"},
+	)
+
+	// 没有权威TTL时以观察到的最大值为原始TTL；偶数个样本取中间两个的平均值
+	ttls := dp.GetSummary("a.com").TTLs
+	if len(ttls) != 2 {
+		t.Fatalf("ttls = %+v, want 2 records", ttls)
+	}
+	want := types.TTLStats{
+		Type: "A", Value: "192.0.2.1", Regions: 4, Min: 40, Max: 300, Median: 175,
+		Original: 300, OriginalSource: types.TTLSourceMaxObserved, MinCacheAge: 0, MaxCacheAge: 260,
+	}
+	assertTTL(t, ttls[0], want)
+	assertTTL(t, ttls[1], types.TTLStats{
+		Type: "A", Value: "192.0.2.2", Regions: 1, Min: 60, Max: 60, Median: 60,
+		Original: 60, OriginalSource: types.TTLSourceMaxObserved,
+	})
+
+	// 权威TTL低于部分区域返回的TTL时，标出这些区域
+	dp.SetAuthoritative("a.com", []types.DNSAnswer{{Type: "A", Value: "192.0.2.1", TTL: 200}})
+	runResults(dp, aResult("r7", "192.0.2.1", 150))
+	want = types.TTLStats{
+		Type: "A", Value: "192.0.2.1", Regions: 5, Min: 40, Max: 300, Median: 150,
+		Original: 200, OriginalSource: types.TTLSourceAuthoritative, MinCacheAge: 0, MaxCacheAge: 160,
+		AboveOriginal: []string{"r1", "r3"},
+	}
+	assertTTL(t, dp.GetSummary("a.com").TTLs[0], want)
+
+	dp.SetAuthoritative("a.com", []types.DNSAnswer{{Type: "A", Value: "192.0.2.2", TTL: 3600}})
+	got := dp.GetSummary("a.com").TTLs[1]
+	if got.MinCacheAge != 3540 || got.MaxCacheAge != 3540 {
+		t.Errorf("cache age = %d..%d, want 3540..3540", got.MinCacheAge, got.MaxCacheAge)
+	}
+}
+
+func assertTTL(t *testing.T, got, want types.TTLStats) {
+	t.Helper()
+	if !slices.Equal(got.AboveOriginal, want.AboveOriginal) {
+		t.Errorf("%s above original = %v, want %v", want.Value, got.AboveOriginal, want.AboveOriginal)
+	}
+	got.AboveOriginal, want.AboveOriginal = nil, nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ttl stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestAnswerSets(t *testing.T) {
+	nx := func(region string) types.RegionResult {
+		return types.RegionResult{Domain: "a.com", Region: region, ErrorClass: types.ErrorRcode, Rcode: "NXDOMAIN", Error: "DNS NXDOMAIN"}
+	}
+	failed := func(region, class string) types.RegionResult {
+		return types.RegionResult{Domain: "a.com", Region: region, ErrorClass: class, Error: class}
+	}
+	dp := NewDNSProcessor()
+	runResults(dp,
+		aResult("r1", "192.0.2.1", 60),
+		aResult("r2", "192.0.2.1", 60),
+		aResult("r3", "192.0.2.1", 60),
+		nx("r4"),
+		nx("r5"),
+		types.RegionResult{Domain: "a.com", Region: "r6", ErrorClass: types.ErrorRcode, Rcode: "SERVFAIL", Error: "DNS SERVFAIL"},
+		types.RegionResult{Domain: "a.com", Region: "r7"},
+		failed("r8", types.ErrorTimeout),
+		failed("r9", types.ErrorTimeout),
+		failed("r10", types.ErrorHTTPStatus),
+		// 部分查询类型失败的区域只作为失败报告
+		aResult("r11", "192.0.2.1", 60),
+		failed("r11", types.ErrorTimeout),
+	)
+
+	summary := dp.GetSummary("a.com")
+	wantSets := []types.AnswerSet{
+		{ID: "S1", Answers: []string{"A 192.0.2.1"}, Regions: []string{"r1", "r2", "r3"}},
+		{ID: "S2", Rcode: "NXDOMAIN", Answers: []string{}, Regions: []string{"r4", "r5"}},
+		{ID: "S3", Rcode: "SERVFAIL", Answers: []string{}, Regions: []string{"r6"}},
+		{ID: "S4", Answers: []string{}, Regions: []string{"r7"}},
+	}
+	if !reflect.DeepEqual(summary.AnswerSets, wantSets) {
+		t.Errorf("answer sets = %+v, want %+v", summary.AnswerSets, wantSets)
+	}
+	wantFailures := []types.FailureSet{
+		{Class: types.ErrorTimeout, Regions: []string{"r11", "r8", "r9"}},
+		{Class: types.ErrorHTTPStatus, Regions: []string{"r10"}},
+	}
+	if !reflect.DeepEqual(summary.Failures, wantFailures) {
+		t.Errorf("failures = %+v, want %+v", summary.Failures, wantFailures)
+	}
+}
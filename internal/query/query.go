@@ -1,40 +1,188 @@
 package query
 
 import (
+	"crypto/rand"
 	"encoding/base64"
-	"log"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/JaveleyQAQ/geodns/internal/config"
 	"github.com/miekg/dns"
 )
 
+// IdentityNames 用于识别解析器软件和实例的CH类查询名
+var IdentityNames = []string{"version.bind", "hostname.bind", "id.server"}
+
+// DefaultUDPSize 启用EDNS0但未指定缓冲区大小时通告的UDP缓冲区大小
+const DefaultUDPSize = 1232
+
+// EDNSOptions 查询报文的EDNS0参数
+type EDNSOptions struct {
+	Enabled bool   // 添加OPT记录，设置其他任意参数时自动启用
+	UDPSize uint16 // 通告的UDP缓冲区大小，0为DefaultUDPSize
+	DO      bool   // 设置DO位，请求DNSSEC记录
+	NSID    bool   // 请求服务器标识 (RFC 5001)
+	Cookie  bool   // 附带客户端cookie (RFC 7873)
+}
+
+// Active 是否需要添加OPT记录
+func (o EDNSOptions) Active() bool {
+	return o.Enabled || o.UDPSize > 0 || o.DO || o.NSID || o.Cookie
+}
+
 type DNSQuery struct {
-	provider *config.DNSProvider
+	edns             EDNSOptions
+	clientCookie     string
+	checkingDisabled bool
+	randomCase       bool
+	qclass           uint16
 }
 
 func NewDNSQuery() *DNSQuery {
 	return &DNSQuery{
-		provider: config.Provider,
+		qclass: dns.ClassINET,
 	}
 }
 
-func (dq *DNSQuery) BuildQuery(domain string, recordType uint16) string {
+// NewMsg 构建DNS查询报文
+func (dq *DNSQuery) NewMsg(domain string, recordType uint16) *dns.Msg {
 	m := new(dns.Msg)
 	m.Id = dns.Id()
 	m.RecursionDesired = true
+	m.CheckingDisabled = dq.checkingDisabled
 	m.Question = make([]dns.Question, 1)
+	name := dns.Fqdn(domain)
+	if dq.randomCase {
+		name = RandomCase(name)
+	}
 	m.Question[0] = dns.Question{
-		Name:   dns.Fqdn(domain),
+		Name:   name,
 		Qtype:  recordType,
-		Qclass: dns.ClassINET,
+		Qclass: dq.qclass,
+	}
+	dq.setEDNS(m)
+	return m
+}
+
+// SetEDNS 设置之后构建的查询报文使用的EDNS0参数
+func (dq *DNSQuery) SetEDNS(opts EDNSOptions) {
+	dq.edns = opts
+	if opts.Cookie && dq.clientCookie == "" {
+		// 客户端cookie在一次运行内保持不变，便于对比各区域返回的服务器cookie
+		buf := make([]byte, 8)
+		rand.Read(buf)
+		dq.clientCookie = hex.EncodeToString(buf)
+	}
+}
+
+// SetCheckingDisabled 设置查询报文的CD位，要求上游不做DNSSEC验证
+func (dq *DNSQuery) SetCheckingDisabled(cd bool) {
+	dq.checkingDisabled = cd
+}
+
+// SetClass 设置查询类，默认IN
+func (dq *DNSQuery) SetClass(class uint16) {
+	dq.qclass = class
+}
+
+// SetRandomCase 设置是否对查询名使用DNS 0x20大小写随机化
+func (dq *DNSQuery) SetRandomCase(enabled bool) {
+	dq.randomCase = enabled
+}
+
+// RandomCase 随机改变名称中字母的大小写 (DNS 0x20)
+func RandomCase(name string) string {
+	buf := []byte(name)
+	bits := make([]byte, (len(buf)+7)/8)
+	rand.Read(bits)
+	for i, c := range buf {
+		if bits[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		switch {
+		case c >= 'a' && c <= 'z':
+			buf[i] = c - 0x20
+		case c >= 'A' && c <= 'Z':
+			buf[i] = c + 0x20
+		}
+	}
+	return string(buf)
+}
+
+// setEDNS 按EDNS0参数为报文添加OPT记录
+func (dq *DNSQuery) setEDNS(m *dns.Msg) {
+	if !dq.edns.Active() {
+		return
 	}
+	size := dq.edns.UDPSize
+	if size == 0 {
+		size = DefaultUDPSize
+	}
+	m.SetEdns0(size, dq.edns.DO)
+
+	opt := m.IsEdns0()
+	if dq.edns.NSID {
+		opt.Option = append(opt.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+	}
+	if dq.edns.Cookie {
+		opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: dq.clientCookie})
+	}
+}
 
+// Encode 将DNS报文编码为base64url（RFC 8484 GET参数格式）
+func Encode(m *dns.Msg) (string, error) {
 	buf, err := m.Pack()
 	if err != nil {
-		log.Fatalf("Error packing DNS query: %v", err)
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// TypeName 返回记录类型的名称，未知类型使用RFC 3597的TYPEnnn格式
+func TypeName(t uint16) string {
+	if name, ok := dns.TypeToString[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("TYPE%d", t)
+}
+
+// ParseType 解析记录类型名称（不区分大小写）、TYPEnnn或数字
+func ParseType(s string) (uint16, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if t, ok := dns.StringToType[s]; ok {
+		return t, nil
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(s, "TYPE"), 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown record type: %s", s)
+	}
+	return uint16(n), nil
+}
+
+// ParseClass 解析查询类名称（IN/CH/HS/NONE/ANY，不区分大小写）、CLASSnnn或数字
+func ParseClass(s string) (uint16, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if c, ok := dns.StringToClass[s]; ok {
+		return c, nil
+	}
+	if s == "CHAOS" {
+		return dns.ClassCHAOS, nil
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(s, "CLASS"), 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown class: %s", s)
+	}
+	return uint16(n), nil
+}
+
+// RData 返回记录的RDATA表示格式，未知类型由miekg/dns按RFC 3597输出为 \# 长度 十六进制
+func RData(rr dns.RR) string {
+	if unknown, ok := rr.(*dns.RFC3597); ok {
+		return fmt.Sprintf("\\# %d %s", len(unknown.Rdata)/2, unknown.Rdata)
 	}
-	return base64.RawURLEncoding.EncodeToString(buf)
+	return strings.TrimSpace(strings.TrimPrefix(rr.String(), rr.Header().String()))
 }
 
 func RandFloat() float64 {
@@ -0,0 +1,44 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseType(t *testing.T) {
+	tests := map[string]uint16{
+		"A":         dns.TypeA,
+		"https":     dns.TypeHTTPS,
+		" Tlsa ":    dns.TypeTLSA,
+		"65":        dns.TypeHTTPS,
+		"TYPE65":    dns.TypeHTTPS,
+		"type52":    dns.TypeTLSA,
+		"65280":     65280,
+		"TYPE65534": 65534,
+	}
+	for s, want := range tests {
+		got, err := ParseType(s)
+		if err != nil || got != want {
+			t.Errorf("ParseType(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+
+	for _, s := range []string{"", "FOO", "TYPE", "TYPEx", "65536", "TYPE65536", "-1", "1.5"} {
+		if got, err := ParseType(s); err == nil {
+			t.Errorf("ParseType(%q) = %d, want an error", s, got)
+		}
+	}
+}
+
+func TestTypeNameRoundTrip(t *testing.T) {
+	for _, rt := range []uint16{dns.TypeA, dns.TypeSVCB, dns.TypeCAA, 65280, 65534} {
+		name := TypeName(rt)
+		if got, err := ParseType(name); err != nil || got != rt {
+			t.Errorf("ParseType(TypeName(%d) = %q) = %d, %v", rt, name, got, err)
+		}
+	}
+	if got := TypeName(65280); got != "TYPE65280" {
+		t.Errorf("TypeName(65280) = %q, want TYPE65280", got)
+	}
+}
@@ -3,33 +3,36 @@ package service
 import (
 	"context"
 	"fmt"
+	"os"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/JaveleyQAQ/geodns/internal/client"
-	"github.com/JaveleyQAQ/geodns/internal/config"
 	"github.com/JaveleyQAQ/geodns/internal/formatter"
 	"github.com/JaveleyQAQ/geodns/internal/input"
 	"github.com/JaveleyQAQ/geodns/internal/processor"
 	"github.com/JaveleyQAQ/geodns/internal/query"
 	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
 )
 
 type DNSQueryService struct {
 	query      *query.DNSQuery
-	client     *client.HTTPClient
+	client     *client.Client
 	processor  *processor.DNSProcessor
 	formatter  *formatter.OutputFormatter
 	input      *input.InputProcessor
 	threads    int
 	outputFile string
+	ttlRef     *client.Client // 查询权威TTL的客户端，为空时不查询
+	showTiming bool           // 是否在最后输出各区域耗时汇总表
 }
 
-func NewDNSQueryService(jsonOutput, responseOnly, showResponse bool, threads int, recordTypes []uint16, outputFile string) *DNSQueryService {
+func NewDNSQueryService(c *client.Client, jsonOutput, responseOnly, showResponse bool, threads int, recordTypes []uint16, outputFile string) *DNSQueryService {
 	return &DNSQueryService{
 		query:      query.NewDNSQuery(),
-		client:     client.NewHTTPClient(),
+		client:     c,
 		processor:  processor.NewDNSProcessor(),
 		formatter:  formatter.NewOutputFormatter(jsonOutput, responseOnly, showResponse, recordTypes, outputFile),
 		input:      input.NewInputProcessor(),
@@ -38,58 +41,49 @@ func NewDNSQueryService(jsonOutput, responseOnly, showResponse bool, threads int
 	}
 }
 
-func (s *DNSQueryService) Query(domain string, recordType uint16) {
-	s.processor.Reset()
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
+// SetEDNS 设置查询报文的EDNS0参数，启用时同时输出各区域返回的EDNS0信息
+func (s *DNSQueryService) SetEDNS(opts query.EDNSOptions) {
+	s.query.SetEDNS(opts)
+	s.formatter.ShowEDNS = opts.Active()
+}
 
-	encodedQuery := s.query.BuildQuery(domain, recordType)
+// SetCheckingDisabled 设置查询报文的CD位
+func (s *DNSQueryService) SetCheckingDisabled(cd bool) {
+	s.query.SetCheckingDisabled(cd)
+}
 
-	var wg sync.WaitGroup
-	resultChan := make(chan types.RegionResult, len(config.Provider.Regions))
+// SetTTLReference 启用TTL分析，c的第一个区域用于查询权威TTL（通常为迭代解析后端）
+func (s *DNSQueryService) SetTTLReference(c *client.Client) {
+	s.ttlRef = c
+	s.formatter.ShowTTL = true
+}
 
-	for _, region := range config.Provider.Regions {
-		wg.Add(1)
-		go s.client.QueryRegion(ctx, domain, region, encodedQuery, recordType, &wg, resultChan)
-	}
+// SetShowSections 设置是否输出各区域响应的头部标志、大小、权威和附加部分
+func (s *DNSQueryService) SetShowSections(show bool) {
+	s.formatter.ShowSections = show
+}
 
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
+// SetShowTiming 设置是否在所有域名输出后输出各区域请求耗时的汇总表（毫秒）
+func (s *DNSQueryService) SetShowTiming(show bool) {
+	s.showTiming = show
+}
 
-	s.processor.ProcessResults(resultChan)
+// SetClass 设置查询类
+func (s *DNSQueryService) SetClass(class uint16) {
+	s.query.SetClass(class)
+}
 
-	summary := s.processor.GetSummary(domain)
-	s.formatter.FormatOutput(summary)
+// SetRandomCase 设置是否对查询名使用DNS 0x20大小写随机化
+func (s *DNSQueryService) SetRandomCase(enabled bool) {
+	s.query.SetRandomCase(enabled)
 }
 
 func (s *DNSQueryService) QueryMultiple(domains []string, recordTypes []uint16) {
-	s.processor.Reset()
-
-	semaphore := make(chan struct{}, s.threads)
-	var wg sync.WaitGroup
-	resultChan := make(chan types.RegionResult, len(domains)*len(recordTypes)*len(config.Provider.Regions))
-
-	for _, domain := range domains {
-		for _, recordType := range recordTypes {
-			wg.Add(1)
-			go func(d string, rt uint16) {
-				defer wg.Done()
-				semaphore <- struct{}{}
-				defer func() { <-semaphore }()
-				s.queryDomain(d, rt, resultChan)
-			}(domain, recordType)
-		}
+	s.collect(domains, recordTypes)
+	if s.ttlRef != nil {
+		s.collectAuthoritative(domains, recordTypes)
 	}
 
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
-
-	s.processor.ProcessResults(resultChan)
-
 	// 检查是否为-ro模式（只输出响应值）
 	if s.formatter.ResponseOnly {
 		allValues := make(map[string]bool)
@@ -120,20 +114,100 @@ func (s *DNSQueryService) QueryMultiple(domains []string, recordTypes []uint16)
 		summary := s.processor.GetSummary(domain)
 		s.formatter.FormatOutput(summary)
 	}
+	if s.showTiming {
+		s.formatter.FormatTiming(s.processor.TimingSummary())
+	}
+}
+
+// Identify 在每个区域查询CH类的 version.bind、hostname.bind、id.server，识别区域背后的解析器软件和实例
+func (s *DNSQueryService) Identify() {
+	s.query.SetClass(dns.ClassCHAOS)
+	s.collect(query.IdentityNames, []uint16{dns.TypeTXT})
+
+	summaries := make([]types.ResultSummary, 0, len(query.IdentityNames))
+	for _, name := range query.IdentityNames {
+		summaries = append(summaries, s.processor.GetSummary(name))
+	}
+	s.formatter.FormatIdentity(summaries)
+}
+
+// collect 并发查询所有域名和记录类型，结果交给processor汇总
+func (s *DNSQueryService) collect(domains []string, recordTypes []uint16) {
+	s.processor.Reset()
+
+	semaphore := make(chan struct{}, s.threads)
+	var wg sync.WaitGroup
+	resultChan := make(chan types.RegionResult, len(domains)*len(recordTypes)*len(s.client.Regions()))
+
+	for _, domain := range domains {
+		for _, recordType := range recordTypes {
+			wg.Add(1)
+			go func(d string, rt uint16) {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+				s.queryDomain(d, rt, resultChan)
+			}(domain, recordType)
+		}
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	s.processor.ProcessResults(resultChan)
+
+	if s.client.BudgetHit() {
+		fmt.Fprintln(os.Stderr, "[WRN] 部分上游当日请求预算已用完，相应区域的查询已跳过")
+	}
+}
+
+// collectAuthoritative 通过参考客户端查询各域名的权威应答，作为TTL分析的原始TTL
+func (s *DNSQueryService) collectAuthoritative(domains []string, recordTypes []uint16) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	region := s.ttlRef.Regions()[0]
+	semaphore := make(chan struct{}, s.threads)
+	var wg sync.WaitGroup
+	resultChan := make(chan types.RegionResult, len(domains)*len(recordTypes))
+
+	for _, domain := range domains {
+		for _, recordType := range recordTypes {
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(d string, rt uint16) {
+				defer func() { <-semaphore }()
+				s.ttlRef.QueryRegion(ctx, d, region, s.query.NewMsg(d, rt), &wg, resultChan)
+			}(domain, recordType)
+		}
+	}
+	wg.Wait()
+	close(resultChan)
+
+	for res := range resultChan {
+		if res.Error != "" {
+			fmt.Fprintf(os.Stderr, "[WRN] %s 权威TTL查询失败: %s\n", res.Domain, res.Error)
+			continue
+		}
+		s.processor.SetAuthoritative(res.Domain, res.Answers)
+	}
 }
 
 func (s *DNSQueryService) queryDomain(domain string, recordType uint16, resultChan chan<- types.RegionResult) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	encodedQuery := s.query.BuildQuery(domain, recordType)
+	msg := s.query.NewMsg(domain, recordType)
+	regions := s.client.Regions()
 
 	var wg sync.WaitGroup
-	domainResultChan := make(chan types.RegionResult, len(config.Provider.Regions))
+	domainResultChan := make(chan types.RegionResult, len(regions))
 
-	for _, region := range config.Provider.Regions {
+	for _, region := range regions {
 		wg.Add(1)
-		go s.client.QueryRegion(ctx, domain, region, encodedQuery, recordType, &wg, domainResultChan)
+		go s.client.QueryRegion(ctx, domain, region, msg.Copy(), &wg, domainResultChan)
 	}
 
 	go func() {
@@ -147,8 +221,12 @@ func (s *DNSQueryService) queryDomain(domain string, recordType uint16, resultCh
 }
 
 func (s *DNSQueryService) Close() {
-    if s.formatter != nil {
-        s.formatter.Close()
-    }
+	if s.formatter != nil {
+		s.formatter.Close()
+	}
+	if s.client != nil {
+		if err := s.client.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "[WRN] 关闭查询后端失败（请求计数或录制可能未保存）: %v\n", err)
+		}
+	}
 }
-
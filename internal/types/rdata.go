@@ -0,0 +1,143 @@
+package types
+
+// 结构化RDATA，DNSAnswer.RData的结构由DNSAnswer.Type决定：下列类型取对应的结构，
+// 其他类型（包括未知类型）一律为RFC 3597格式的 UnknownData
+
+// AddressData A/AAAA记录
+type AddressData struct {
+	Address string `json:"address"`
+}
+
+// TargetData CNAME/NS/PTR/DNAME记录
+type TargetData struct {
+	Target string `json:"target"`
+}
+
+// MXData MX记录
+type MXData struct {
+	Preference uint16 `json:"preference"`
+	Exchange   string `json:"exchange"`
+}
+
+// SRVData SRV记录
+type SRVData struct {
+	Priority uint16 `json:"priority"`
+	Weight   uint16 `json:"weight"`
+	Port     uint16 `json:"port"`
+	Target   string `json:"target"`
+}
+
+// SOAData SOA记录
+type SOAData struct {
+	MName   string `json:"mname"`
+	RName   string `json:"rname"`
+	Serial  uint32 `json:"serial"`
+	Refresh uint32 `json:"refresh"`
+	Retry   uint32 `json:"retry"`
+	Expire  uint32 `json:"expire"`
+	Minimum uint32 `json:"minimum"`
+}
+
+// TXTData TXT/SPF记录，保留各字符串的边界
+type TXTData struct {
+	Strings []string `json:"strings"`
+}
+
+// CAAData CAA记录
+type CAAData struct {
+	Flag  uint8  `json:"flag"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+// DSData DS/CDS记录
+type DSData struct {
+	KeyTag     uint16 `json:"key_tag"`
+	Algorithm  uint8  `json:"algorithm"`
+	DigestType uint8  `json:"digest_type"`
+	Digest     string `json:"digest"` // 十六进制
+}
+
+// DNSKEYData DNSKEY/CDNSKEY记录
+type DNSKEYData struct {
+	Flags     uint16 `json:"flags"`
+	Protocol  uint8  `json:"protocol"`
+	Algorithm uint8  `json:"algorithm"`
+	PublicKey string `json:"public_key"` // base64
+	KeyTag    uint16 `json:"key_tag"`    // 由公钥计算
+}
+
+// RRSIGData RRSIG记录
+type RRSIGData struct {
+	TypeCovered string `json:"type_covered"`
+	Algorithm   uint8  `json:"algorithm"`
+	Labels      uint8  `json:"labels"`
+	OriginalTTL uint32 `json:"original_ttl"`
+	Expiration  string `json:"expiration"` // RFC 3339
+	Inception   string `json:"inception"`  // RFC 3339
+	KeyTag      uint16 `json:"key_tag"`
+	SignerName  string `json:"signer_name"`
+	Signature   string `json:"signature"` // base64
+}
+
+// NSECData NSEC记录
+type NSECData struct {
+	NextDomain string   `json:"next_domain"`
+	Types      []string `json:"types"`
+}
+
+// NSEC3Data NSEC3记录
+type NSEC3Data struct {
+	HashAlgorithm uint8    `json:"hash_algorithm"`
+	Flags         uint8    `json:"flags"`
+	Iterations    uint16   `json:"iterations"`
+	Salt          string   `json:"salt"`        // 十六进制，无盐时为空
+	NextDomain    string   `json:"next_domain"` // base32hex编码的下一个哈希
+	Types         []string `json:"types"`
+}
+
+// NSEC3PARAMData NSEC3PARAM记录
+type NSEC3PARAMData struct {
+	HashAlgorithm uint8  `json:"hash_algorithm"`
+	Flags         uint8  `json:"flags"`
+	Iterations    uint16 `json:"iterations"`
+	Salt          string `json:"salt"` // 十六进制，无盐时为空
+}
+
+// TLSAData TLSA/SMIMEA记录
+type TLSAData struct {
+	Usage        uint8  `json:"usage"`
+	Selector     uint8  `json:"selector"`
+	MatchingType uint8  `json:"matching_type"`
+	Certificate  string `json:"certificate"` // 十六进制
+}
+
+// SVCBData SVCB/HTTPS记录，优先级为0时为别名模式
+type SVCBData struct {
+	Priority uint16            `json:"priority"`
+	Target   string            `json:"target"`
+	Params   map[string]string `json:"params,omitempty"` // 参数名到表示格式的值，如 alpn: "h2,h3"
+}
+
+// NAPTRData NAPTR记录
+type NAPTRData struct {
+	Order       uint16 `json:"order"`
+	Preference  uint16 `json:"preference"`
+	Flags       string `json:"flags"`
+	Service     string `json:"service"`
+	Regexp      string `json:"regexp"`
+	Replacement string `json:"replacement"`
+}
+
+// SSHFPData SSHFP记录
+type SSHFPData struct {
+	Algorithm   uint8  `json:"algorithm"`
+	FPType      uint8  `json:"fp_type"`
+	Fingerprint string `json:"fingerprint"` // 十六进制
+}
+
+// UnknownData RFC 3597通用格式，用于没有专门结构的类型
+type UnknownData struct {
+	RDLength int    `json:"rdlength"`
+	Data     string `json:"data"` // 十六进制
+}
@@ -10,26 +10,207 @@ import "sort"
 
 // DNSAnswer DNS答案
 type DNSAnswer struct {
-	Type  string `json:"type"`
-	Value string `json:"value"`
+	Name  string      `json:"name"`          // 记录所有者名称
+	Type  string      `json:"type"`          // 记录类型名称，未知类型为TYPEnnn
+	TTL   uint32      `json:"ttl"`           // 区域返回的剩余TTL
+	Value string      `json:"value"`         // RDATA表示格式（TXT为拼接后的文本）
+	RData interface{} `json:"rdata"`         // 结构化RDATA，见 rdata.go
+	Geo   *GeoInfo    `json:"geo,omitempty"` // A/AAAA地址的地理位置和ASN，需指定MMDB数据库
 }
 
+// GeoInfo 从本地MMDB数据库查询的IP地理位置和ASN信息
+type GeoInfo struct {
+	Country     string   `json:"country,omitempty"` // ISO国家代码
+	CountryName string   `json:"country_name,omitempty"`
+	City        string   `json:"city,omitempty"`
+	Latitude    *float64 `json:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty"`
+	ASN         uint     `json:"asn,omitempty"`
+	Org         string   `json:"org,omitempty"`
+}
+
+// 区域查询错误类别
+const (
+	ErrorTimeout    = "timeout"     // 超时
+	ErrorConnect    = "connect"     // 连接失败（拒绝连接、地址解析失败、连接在响应前被断开等）
+	ErrorTLS        = "tls"         // TLS握手或证书校验失败
+	ErrorHTTPStatus = "http_status" // 非200的HTTP响应
+	ErrorMalformed  = "malformed"   // 响应体不是合法的DNS报文
+	ErrorRcode      = "rcode"       // DNS响应码非NOERROR
+	ErrorSkipped    = "skipped"     // 区域熔断或预算用完而未查询
+	ErrorOther      = "other"       // 其他错误
+)
+
 // RegionResult 区域查询结果
 type RegionResult struct {
-	Domain   string      `json:"domain"`
-	Region   string      `json:"region"`
-	Answers  []DNSAnswer `json:"answers"`
-	IPs      []string    `json:"-"`
-	CNAMEs   []string    `json:"-"`
-	RawBytes []byte      `json:"-"`
-	Error    string      `json:"error,omitempty"`
+	Domain     string      `json:"domain"`
+	Region     string      `json:"region"`
+	Answers    []DNSAnswer `json:"answers"`
+	IPs        []string    `json:"-"`
+	CNAMEs     []string    `json:"-"`
+	RawBytes   []byte      `json:"-"`
+	Authority  []DNSAnswer `json:"authority,omitempty"`  // 权威部分（如否定应答的SOA、转介的NS）
+	Additional []DNSAnswer `json:"additional,omitempty"` // 附加部分，不含OPT记录
+	Flags      *Flags      `json:"flags,omitempty"`
+	Size       int         `json:"size,omitempty"` // 响应报文字节数
+	Rcode      string      `json:"rcode,omitempty"`
+	HTTPStatus int         `json:"http_status,omitempty"`
+	ECS        *ECSInfo    `json:"ecs,omitempty"`
+	EDNS       *EDNSInfo   `json:"edns,omitempty"`
+	DNSSEC     *DNSSECInfo `json:"dnssec,omitempty"`
+	Chain      *CNAMEChain `json:"chain,omitempty"`    // 应答中的CNAME链，没有CNAME时为空
+	Followed   []DNSAnswer `json:"followed,omitempty"` // -follow 继续查询链末端得到的记录，不属于原始响应
+	Trace      []TraceHop  `json:"trace,omitempty"`
+	Mismatches []string    `json:"mismatches,omitempty"` // 响应与查询不一致之处（ID、问题、0x20大小写），可能被篡改
+	Timing     *Timing     `json:"timing,omitempty"`     // 最后一次请求的耗时分解
+	ErrorClass string      `json:"error_class,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// ResultStats 区域结果统计
+type ResultStats struct {
+	Total      int            `json:"total"`
+	Succeeded  int            `json:"succeeded"`
+	Mismatched int            `json:"mismatched,omitempty"` // 响应与查询不一致的区域数
+	Errors     map[string]int `json:"errors,omitempty"`     // 按错误类别计数，DNS响应码错误按响应码名称计数
+}
+
+// CNAMEChain 从查询名开始重建的CNAME链
+type CNAMEChain struct {
+	Names    []string `json:"names"`              // 查询名 → CNAME目标 → ... → 最终名称
+	Type     string   `json:"type"`               // 查询类型
+	Final    []string `json:"final,omitempty"`    // 最终名称上查询类型的记录值
+	Followed int      `json:"followed,omitempty"` // 为补全链额外发出的查询次数
+	Loop     bool     `json:"loop,omitempty"`     // 链中出现环路，Names最后一项为重复的名称
+	TooLong  bool     `json:"too_long,omitempty"` // 链超过最大长度而被截断
+	Rcode    string   `json:"rcode,omitempty"`    // 继续查询链末端得到的非NOERROR响应码，如NXDOMAIN、SERVFAIL
+	Error    string   `json:"error,omitempty"`    // 继续查询链末端失败时的错误
+}
+
+// TraceHop 迭代解析中的一跳
+type TraceHop struct {
+	Zone          string   `json:"zone"`
+	Server        string   `json:"server"`
+	Rcode         string   `json:"rcode,omitempty"`
+	Authoritative bool     `json:"authoritative"`
+	ReferralZone  string   `json:"referral_zone,omitempty"`
+	Referral      []string `json:"referral,omitempty"`
+	Glue          []string `json:"glue,omitempty"`
+	Answers       []string `json:"answers,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// Flags 响应头部标志
+type Flags struct {
+	AA bool `json:"aa"` // 权威应答
+	TC bool `json:"tc"` // 截断
+	RA bool `json:"ra"` // 支持递归
+	AD bool `json:"ad"` // 数据已验证
+	CD bool `json:"cd"` // 禁用检查
+}
+
+// Timing 一次区域请求的耗时分解（毫秒），DNS解析、连接和TLS阶段只在HTTP类后端新建连接时出现
+type Timing struct {
+	DNSLookup    float64 `json:"dns_lookup_ms,omitempty"`
+	Connect      float64 `json:"connect_ms,omitempty"`
+	TLSHandshake float64 `json:"tls_handshake_ms,omitempty"`
+	FirstByte    float64 `json:"first_byte_ms,omitempty"` // 从请求开始到收到响应首字节
+	Total        float64 `json:"total_ms"`                // 不含限速排队时间
+	Wait         float64 `json:"wait_ms,omitempty"`       // 限速排队时间
+	Reused       bool    `json:"reused,omitempty"`        // 复用了已有连接
+}
+
+// RegionTiming 区域在本次运行中所有请求的耗时汇总（毫秒，各阶段为中位数）
+type RegionTiming struct {
+	Region       string  `json:"region"`
+	Requests     int     `json:"requests"`
+	Failed       int     `json:"failed"`
+	DNSLookup    float64 `json:"dns_lookup_ms"`
+	Connect      float64 `json:"connect_ms"`
+	TLSHandshake float64 `json:"tls_handshake_ms"`
+	FirstByte    float64 `json:"first_byte_ms"`
+	TotalMedian  float64 `json:"total_median_ms"`
+	TotalP95     float64 `json:"total_p95_ms"`
+	TotalMax     float64 `json:"total_max_ms"`
+}
+
+// ECSInfo 响应中的EDNS Client Subnet信息
+type ECSInfo struct {
+	Subnet string `json:"subnet"`
+	Scope  uint8  `json:"scope"`
+}
+
+// EDNSInfo 响应中的EDNS0信息
+type EDNSInfo struct {
+	Version      uint8  `json:"version"`
+	UDPSize      uint16 `json:"udp_size"`
+	DO           bool   `json:"do"`
+	NSID         string `json:"nsid,omitempty"`          // 服务器标识，可打印时为文本，否则为十六进制
+	ClientCookie string `json:"client_cookie,omitempty"` // 十六进制
+	ServerCookie string `json:"server_cookie,omitempty"` // 十六进制
+}
+
+// DNSSEC验证结果
+const (
+	DNSSECSecure        = "secure"        // 签名链验证通过
+	DNSSECInsecure      = "insecure"      // 区域未签名或委派链上缺少DS
+	DNSSECBogus         = "bogus"         // 签名缺失、过期或验证失败
+	DNSSECIndeterminate = "indeterminate" // 无法获取验证所需的记录，或缺少有效的否定证明
+)
+
+// DNSSECInfo 区域响应的DNSSEC验证信息
+type DNSSECInfo struct {
+	Status string `json:"status"`           // 本地验证结果
+	AD     bool   `json:"ad"`               // 上游设置的AD位
+	CD     bool   `json:"cd"`               // 查询是否设置了CD位
+	Reason string `json:"reason,omitempty"` // 非secure时的原因
 }
 
 // ResultSummary 结果汇总
 type ResultSummary struct {
-	Domain        string              `json:"domain"`
-	Results       []RegionResult      `json:"results"`
-	UniqueAnswers map[string][]string `json:"unique_answers"`
+	Domain        string                         `json:"domain"`
+	Results       []RegionResult                 `json:"results"`
+	UniqueAnswers map[string][]string            `json:"unique_answers"`
+	AnswerRegions map[string]map[string][]string `json:"answer_regions"`     // 类型 -> 值 -> 返回该值的区域
+	AnswerSets    []AnswerSet                    `json:"answer_sets"`        // 返回相同记录集合（或相同错误响应码）的区域分组
+	Failures      []FailureSet                   `json:"failures,omitempty"` // 因传输失败没有得到响应的区域，按错误类别分组
+	Stats         ResultStats                    `json:"stats"`
+	TTLs          []TTLStats                     `json:"ttls,omitempty"`
+}
+
+// AnswerSet 返回完全相同记录集合的一组区域，返回NXDOMAIN、SERVFAIL等响应码的区域按响应码单独成组
+type AnswerSet struct {
+	ID      string   `json:"id"`              // S1、S2...，按区域数降序编号
+	Rcode   string   `json:"rcode,omitempty"` // 非NOERROR的响应码，多个查询类型的响应码不同时以逗号分隔
+	Answers []string `json:"answers"`         // "类型 值"，排序后的记录集合，空表示无记录
+	Regions []string `json:"regions"`
+}
+
+// FailureSet 因超时、连接失败、HTTP错误等没有得到DNS响应的一组区域
+type FailureSet struct {
+	Class   string   `json:"class"` // 错误类别，同一区域的多个查询类型失败类别不同时以逗号分隔
+	Regions []string `json:"regions"`
+}
+
+// TTL参考值来源
+const (
+	TTLSourceAuthoritative = "authoritative" // 权威服务器返回的TTL
+	TTLSourceMaxObserved   = "max_observed"  // 未查询权威服务器时，取各区域观察到的最大TTL
+)
+
+// TTLStats 单条记录在各区域的TTL统计
+type TTLStats struct {
+	Type           string   `json:"type"`
+	Value          string   `json:"value"`
+	Regions        int      `json:"regions"` // 返回该记录的区域数
+	Min            uint32   `json:"min"`
+	Max            uint32   `json:"max"`
+	Median         uint32   `json:"median"`
+	Original       uint32   `json:"original"`                 // 原始TTL
+	OriginalSource string   `json:"original_source"`          // 原始TTL的来源
+	MinCacheAge    uint32   `json:"min_cache_age"`            // 估计的缓存时长（原始TTL减观察到的TTL）的最小值
+	MaxCacheAge    uint32   `json:"max_cache_age"`            // 估计的缓存时长的最大值
+	AboveOriginal  []string `json:"above_original,omitempty"` // 返回的TTL高于权威TTL的区域
 }
 
 // Keys 获取map的键并排序
@@ -0,0 +1,231 @@
+// Package geodnstest 提供进程内的dns.surf模拟服务器，用于测试和演示。
+//
+// 服务器同时支持vercel (/api/region/{region}?dns=...) 和cloudflare (/?dns=...&region=...)
+// 两种接口格式，按区域返回预设的应答、响应码、延迟或失败。
+//
+// geodns的查询后端位于internal包中，本模块之外的代码以子进程方式运行geodns命令行，
+// 通过 Args 返回的 -surf-url 参数（或 Env 返回的 DNS_SURF_URL 环境变量）让其连接到模拟服务器：
+//
+//	srv := geodnstest.NewServer()
+//	defer srv.Close()
+//	srv.Script("hnd1", geodnstest.Response{Answers: []string{"example.com. 60 IN A 192.0.2.1"}})
+//	srv.Script("fra1", geodnstest.Response{Status: http.StatusTooManyRequests, RetryAfter: "1"})
+//	cmd := exec.Command("geodns", append(srv.Args(), "-d", "example.com", "-json")...)
+//	out, err := cmd.Output()
+//	for _, req := range srv.Requests() {
+//		if req.Shape == geodnstest.ShapeVercel { ... }
+//	}
+package geodnstest
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ShapeVercel 和 ShapeCloudflare 为 Request.Shape 的取值，与geodns的 -m vercel/cloudflare 对应
+const (
+	ShapeVercel     = "vercel"
+	ShapeCloudflare = "cloudflare"
+)
+
+// Response 区域的一次预设响应
+type Response struct {
+	Answers    []string      // 应答记录，zone文件格式，如 "example.com. 60 IN A 192.0.2.1"
+	Rcode      int           // DNS响应码，默认NOERROR
+	NSID       string        // 查询请求NSID时返回的服务器标识
+	Delay      time.Duration // 响应前的延迟，客户端取消请求时提前返回
+	Status     int           // 非0且非200时返回该HTTP状态码而不是DNS报文
+	RetryAfter string        // Status非200时附带的Retry-After头
+	Body       []byte        // 非空时原样作为响应体返回（用于模拟畸形响应）
+	Drop       bool          // 直接断开连接，不返回任何内容
+}
+
+// Request 服务器收到的一次查询
+type Request struct {
+	Shape    string   // 接口格式：ShapeVercel 或 ShapeCloudflare
+	Region   string   // 请求的区域
+	Resolver string   // vercel接口的resolver参数
+	Query    *dns.Msg // 解码后的查询报文
+}
+
+// Server dns.surf 模拟服务器
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	fallback Response
+	scripts  map[string][]Response
+	requests []Request
+}
+
+// NewServer 启动模拟服务器，未设置脚本的区域返回空的NOERROR应答
+func NewServer() *Server {
+	s := &Server{
+		scripts: make(map[string][]Response),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Script 设置区域的响应序列，每次请求依次取用，最后一个响应会一直重复
+func (s *Server) Script(region string, responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[region] = responses
+}
+
+// Default 设置未配置脚本的区域使用的响应
+func (s *Server) Default(resp Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = resp
+}
+
+// Requests 返回目前收到的所有请求
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Calls 返回区域收到的请求次数
+func (s *Server) Calls(region string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.requests {
+		if r.Region == region {
+			n++
+		}
+	}
+	return n
+}
+
+// Args 返回让geodns命令行连接到模拟服务器的参数，形如 -surf-url http://127.0.0.1:port
+func (s *Server) Args() []string {
+	return []string{"-surf-url", s.URL}
+}
+
+// Env 返回让geodns命令行连接到模拟服务器的环境变量，形如 DNS_SURF_URL=http://127.0.0.1:port
+func (s *Server) Env() string {
+	return "DNS_SURF_URL=" + s.URL
+}
+
+// next 记录请求并取出区域的下一个响应
+func (s *Server) next(req Request) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+
+	script, ok := s.scripts[req.Region]
+	if !ok || len(script) == 0 {
+		return s.fallback
+	}
+	resp := script[0]
+	if len(script) > 1 {
+		s.scripts[req.Region] = script[1:]
+	}
+	return resp
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	req := Request{Region: r.URL.Query().Get("region")}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/region/"):
+		req.Shape = ShapeVercel
+		req.Region = strings.TrimPrefix(r.URL.Path, "/api/region/")
+		req.Resolver = r.URL.Query().Get("resolver")
+	case r.URL.Path == "/":
+		req.Shape = ShapeCloudflare
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if req.Region == "" {
+		http.Error(w, "missing region", http.StatusBadRequest)
+		return
+	}
+
+	wire, err := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+	if err != nil {
+		http.Error(w, "invalid dns parameter", http.StatusBadRequest)
+		return
+	}
+	req.Query = new(dns.Msg)
+	if err := req.Query.Unpack(wire); err != nil || len(req.Query.Question) == 0 {
+		http.Error(w, "invalid dns message", http.StatusBadRequest)
+		return
+	}
+
+	resp := s.next(req)
+	if resp.Delay > 0 {
+		select {
+		case <-time.After(resp.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case resp.Drop:
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	case resp.Status != 0 && resp.Status != http.StatusOK:
+		if resp.RetryAfter != "" {
+			w.Header().Set("Retry-After", resp.RetryAfter)
+		}
+		http.Error(w, http.StatusText(resp.Status), resp.Status)
+		return
+	case resp.Body != nil:
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(resp.Body)
+		return
+	}
+
+	body, err := buildReply(req.Query, resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/dns-message")
+	w.Write(body)
+}
+
+// buildReply 根据预设响应构建wire格式的应答
+func buildReply(query *dns.Msg, resp Response) ([]byte, error) {
+	m := new(dns.Msg)
+	m.SetRcode(query, resp.Rcode)
+	m.RecursionAvailable = true
+	for _, s := range resp.Answers {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid answer %q: %w", s, err)
+		}
+		m.Answer = append(m.Answer, rr)
+	}
+
+	// 查询带OPT记录时回显EDNS0，并按需返回NSID
+	if opt := query.IsEdns0(); opt != nil {
+		m.SetEdns0(opt.UDPSize(), opt.Do())
+		for _, o := range opt.Option {
+			if o.Option() == dns.EDNS0NSID && resp.NSID != "" {
+				reply := m.IsEdns0()
+				reply.Option = append(reply.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: hex.EncodeToString([]byte(resp.NSID))})
+			}
+		}
+	}
+	return m.Pack()
+}
@@ -0,0 +1,165 @@
+package geodnstest
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JaveleyQAQ/geodns/internal/backend"
+	"github.com/JaveleyQAQ/geodns/internal/client"
+	"github.com/JaveleyQAQ/geodns/internal/config"
+	"github.com/JaveleyQAQ/geodns/internal/types"
+	"github.com/miekg/dns"
+)
+
+// newBackend 返回连接到模拟服务器的vercel或cloudflare后端
+func newBackend(t *testing.T, srv *Server, mode string, regions ...string) backend.Backend {
+	t.Helper()
+	path := config.VercelPath
+	if mode == config.ModeCloudflare {
+		path = config.CloudflarePath
+	}
+	b, err := backend.New(backend.Options{
+		Mode:     mode,
+		Resolver: "cf",
+		Provider: &config.DNSProvider{BaseURL: srv.URL + path, Regions: regions},
+		Client:   &http.Client{Timeout: 5 * time.Second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// queryRegion 通过client.Client查询一个区域的A记录
+func queryRegion(ctx context.Context, c *client.Client, region string) types.RegionResult {
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	results := make(chan types.RegionResult, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	c.QueryRegion(ctx, "example.com", region, m, &wg, results)
+	return <-results
+}
+
+// modes 模拟服务器支持的两种接口格式
+var modes = []string{config.ModeVercel, config.ModeCloudflare}
+
+var noRetry = client.RetryPolicy{}
+
+func TestScriptedAnswersAndRcodes(t *testing.T) {
+	for _, mode := range modes {
+		t.Run(mode, func(t *testing.T) {
+			srv := NewServer()
+			defer srv.Close()
+			srv.Script("ok", Response{Answers: []string{"example.com. 60 IN A 192.0.2.1"}})
+			srv.Script("nx", Response{Rcode: dns.RcodeNameError})
+			c := client.NewClient(newBackend(t, srv, mode, "ok", "nx"), noRetry, nil)
+
+			res := queryRegion(context.Background(), c, "ok")
+			if res.Error != "" || len(res.Answers) != 1 || res.Answers[0].Value != "192.0.2.1" {
+				t.Errorf("ok: answers = %+v, error = %q", res.Answers, res.Error)
+			}
+
+			res = queryRegion(context.Background(), c, "nx")
+			if res.Rcode != "NXDOMAIN" || res.ErrorClass != types.ErrorRcode {
+				t.Errorf("nx: rcode = %q, class = %q", res.Rcode, res.ErrorClass)
+			}
+
+			requests := srv.Requests()
+			if len(requests) != 2 {
+				t.Fatalf("got %d requests, want 2", len(requests))
+			}
+			for _, req := range requests {
+				if req.Shape != mode {
+					t.Errorf("request shape = %q, want %q", req.Shape, mode)
+				}
+				if mode == config.ModeVercel && req.Resolver != "cf" {
+					t.Errorf("vercel resolver = %q, want cf", req.Resolver)
+				}
+				if req.Query.Question[0].Name != "example.com." {
+					t.Errorf("query name = %q", req.Query.Question[0].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for _, mode := range modes {
+		t.Run(mode, func(t *testing.T) {
+			srv := NewServer()
+			defer srv.Close()
+			srv.Script("r",
+				Response{Status: http.StatusTooManyRequests, RetryAfter: "1"},
+				Response{Answers: []string{"example.com. 60 IN A 192.0.2.1"}},
+			)
+			retry := client.RetryPolicy{Retries: 1, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second}
+			c := client.NewClient(newBackend(t, srv, mode, "r"), retry, nil)
+
+			start := time.Now()
+			res := queryRegion(context.Background(), c, "r")
+			if res.Error != "" || len(res.Answers) != 1 {
+				t.Fatalf("answers = %+v, error = %q", res.Answers, res.Error)
+			}
+			// 重试等待Retry-After指定的1秒（未超过上限），而不是1毫秒的退避时间
+			if elapsed := time.Since(start); elapsed < time.Second {
+				t.Errorf("retried after %v, want at least 1s", elapsed)
+			}
+			if n := srv.Calls("r"); n != 2 {
+				t.Errorf("got %d calls, want 2", n)
+			}
+		})
+	}
+}
+
+func TestFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		resp      Response
+		wantClass string
+		wantHTTP  int
+	}{
+		{"status", Response{Status: http.StatusTooManyRequests, RetryAfter: "30"}, types.ErrorHTTPStatus, http.StatusTooManyRequests},
+		{"body", Response{Body: []byte("not a dns message")}, types.ErrorMalformed, 0},
+		{"drop", Response{Drop: true}, types.ErrorConnect, 0},
+		{"delay", Response{Delay: 2 * time.Second}, types.ErrorTimeout, 0},
+	}
+	for _, mode := range modes {
+		for _, tt := range tests {
+			t.Run(mode+"/"+tt.name, func(t *testing.T) {
+				srv := NewServer()
+				defer srv.Close()
+				srv.Script("r", tt.resp)
+				c := client.NewClient(newBackend(t, srv, mode, "r"), noRetry, nil)
+
+				ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+				defer cancel()
+				res := queryRegion(ctx, c, "r")
+				if res.ErrorClass != tt.wantClass || res.HTTPStatus != tt.wantHTTP {
+					t.Errorf("class = %q, http = %d, want %q, %d (error: %s)", res.ErrorClass, res.HTTPStatus, tt.wantClass, tt.wantHTTP, res.Error)
+				}
+				if len(res.Answers) != 0 {
+					t.Errorf("answers = %+v, want none", res.Answers)
+				}
+			})
+		}
+	}
+}
+
+func TestDefaultResponse(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.Default(Response{Answers: []string{"example.com. 60 IN A 192.0.2.9"}})
+	c := client.NewClient(newBackend(t, srv, config.ModeCloudflare, "any"), noRetry, nil)
+
+	res := queryRegion(context.Background(), c, "any")
+	if len(res.Answers) != 1 || res.Answers[0].Value != "192.0.2.9" {
+		t.Errorf("answers = %+v, error = %q", res.Answers, res.Error)
+	}
+	if srv.Calls("any") != 1 {
+		t.Errorf("got %d calls, want 1", srv.Calls("any"))
+	}
+}